@@ -0,0 +1,133 @@
+package coverart
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// The Hit represents the outcome of a lookup made by one of the providers of
+// an Aggregator
+type Hit struct {
+	Provider string
+	Result   Result
+	Err      error
+}
+
+// The Aggregator sends the same lookup to several providers concurrently and
+// collects all of their results
+type Aggregator struct {
+	Providers []Provider
+
+	// Timeout is the overall deadline of a lookup, the providers which did not
+	// answer in time are reported with a context error. No deadline is added
+	// when it is zero.
+	Timeout time.Duration
+}
+
+// NewAggregator returns an Aggregator querying the given providers
+func NewAggregator(providers ...Provider) *Aggregator {
+	return &Aggregator{Providers: providers}
+}
+
+// Album gets the album artwork from every provider concurrently
+func (a *Aggregator) Album(ctx context.Context, album string, artist string) []Hit {
+	return a.lookup(ctx, func(ctx context.Context, p Provider) (Result, error) {
+		return p.Album(ctx, album, artist)
+	})
+}
+
+// Track gets the track artwork from every provider concurrently
+func (a *Aggregator) Track(ctx context.Context, track string, artist string) []Hit {
+	return a.lookup(ctx, func(ctx context.Context, p Provider) (Result, error) {
+		return p.Track(ctx, track, artist)
+	})
+}
+
+// Artist gets the artist artwork from every provider concurrently
+func (a *Aggregator) Artist(ctx context.Context, artist string) []Hit {
+	return a.lookup(ctx, func(ctx context.Context, p Provider) (Result, error) {
+		return p.Artist(ctx, artist)
+	})
+}
+
+type indexedHit struct {
+	index int
+	hit   Hit
+}
+
+// Runs the lookup on every provider and returns the ranked hits, one per
+// provider
+func (a *Aggregator) lookup(ctx context.Context, fn func(context.Context, Provider) (Result, error)) []Hit {
+	if a.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.Timeout)
+		defer cancel()
+	}
+
+	hits := make([]Hit, len(a.Providers))
+	done := make([]bool, len(a.Providers))
+	ch := make(chan indexedHit, len(a.Providers))
+
+	for i, p := range a.Providers {
+		go func(i int, p Provider) {
+			res, err := fn(ctx, p)
+			ch <- indexedHit{i, Hit{p.Name(), res, err}}
+		}(i, p)
+	}
+
+collect:
+	for range a.Providers {
+		select {
+		case h := <-ch:
+			hits[h.index] = h.hit
+			done[h.index] = true
+		case <-ctx.Done():
+			// The results which arrived with the deadline are kept
+			drain(ch, hits, done)
+			for i, p := range a.Providers {
+				if !done[i] {
+					hits[i] = Hit{Provider: p.Name(), Err: ctx.Err()}
+				}
+			}
+			break collect
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return rank(hits[i]) > rank(hits[j])
+	})
+
+	return hits
+}
+
+// Used to collect the hits already sent on the channel, without waiting for
+// the others
+func drain(ch chan indexedHit, hits []Hit, done []bool) {
+	for {
+		select {
+		case h := <-ch:
+			hits[h.index] = h.hit
+			done[h.index] = true
+		default:
+			return
+		}
+	}
+}
+
+// Used to rank the hits, successful ones first then by the largest size of
+// artwork available. Equal hits keep the order of the providers.
+func rank(h Hit) int {
+	switch {
+	case h.Err != nil:
+		return 0
+	case len(h.Result.Large) > 0:
+		return 4
+	case len(h.Result.Medium) > 0:
+		return 3
+	case len(h.Result.Small) > 0:
+		return 2
+	}
+
+	return 1
+}
@@ -0,0 +1,394 @@
+package artwork_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/piraveen/go-coverart/artwork"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pngImage returns a PNG encoded image of the given dimensions
+func pngImage(t *testing.T, width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusUnauthorized, artwork.ErrUnauthorized},
+		{http.StatusNotFound, artwork.ErrNotFound},
+		{http.StatusTooManyRequests, artwork.ErrRateLimited},
+		{http.StatusBadGateway, artwork.ErrUpstream},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+		resp.Header.Set("Retry-After", "3")
+
+		err := artwork.ResponseError(resp, "failed")
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%d: expected %v, got %v", test.status, test.expected, err)
+		}
+
+		if err.Error() != "failed" {
+			t.Errorf("%d: unexpected message %q", test.status, err.Error())
+		}
+	}
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "3")
+
+	var rateErr *artwork.RateLimitError
+	if !errors.As(artwork.ResponseError(resp, ""), &rateErr) || rateErr.RetryAfter != 3*time.Second {
+		t.Errorf("expected a rate limit error with a 3s delay, got %v", rateErr)
+	}
+
+	var upErr *artwork.UpstreamError
+	resp = &http.Response{StatusCode: http.StatusServiceUnavailable}
+	if !errors.As(artwork.ResponseError(resp, ""), &upErr) || upErr.StatusCode != 503 {
+		t.Errorf("expected an upstream error with a 503 status, got %v", upErr)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := artwork.ParseRetryAfter("120"); d != 2*time.Minute {
+		t.Errorf("expected 2m, got %v", d)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d := artwork.ParseRetryAfter(date); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("expected about 1h, got %v", d)
+	}
+
+	if d := artwork.ParseRetryAfter("soon"); d != 0 {
+		t.Errorf("expected no delay, got %v", d)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := artwork.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	attempts := 0
+	err := policy.Do(context.Background(), func() error {
+		attempts++
+		return &artwork.UpstreamError{StatusCode: 502, Message: "Bad Gateway"}
+	})
+
+	if !errors.Is(err, artwork.ErrUpstream) || attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d and %v", attempts, err)
+	}
+
+	attempts = 0
+	err = policy.Do(context.Background(), func() error {
+		attempts++
+		return artwork.NotFound("No match was found")
+	})
+
+	if !errors.Is(err, artwork.ErrNotFound) || attempts != 1 {
+		t.Errorf("expected a single attempt, got %d and %v", attempts, err)
+	}
+
+	attempts = 0
+	policy.MaxDelay = time.Second
+	err = policy.Do(context.Background(), func() error {
+		attempts++
+		return &artwork.RateLimitError{RetryAfter: time.Hour, Message: "Slow down"}
+	})
+
+	if attempts != 1 {
+		t.Errorf("expected no retry when asked to wait longer than the max delay, got %d", attempts)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := artwork.RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, d := range expected {
+		if delay := policy.Delay(i+1, errors.New("failed")); delay != d {
+			t.Errorf("retry %d: expected %v, got %v", i+1, d, delay)
+		}
+	}
+
+	rateErr := &artwork.RateLimitError{RetryAfter: 3 * time.Second}
+	if delay := policy.Delay(1, rateErr); delay != 3*time.Second {
+		t.Errorf("expected the Retry-After delay, got %v", delay)
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		if delay := policy.Delay(1, errors.New("failed")); delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Errorf("unexpected jittered delay %v", delay)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	data := pngImage(t, 40, 30)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cover.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data)
+	})
+	mux.HandleFunc("/sniffed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/broken.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data[:len(data)/2])
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	fetcher := artwork.NewFetcher()
+	fetcher.Retry = artwork.NoRetry
+
+	for _, path := range []string{"/cover.png", "/sniffed"} {
+		img, err := fetcher.Fetch(context.Background(), ts.URL+path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", path, err)
+		}
+
+		if img.Format != "png" || img.MIMEType() != "image/png" || img.Width != 40 || img.Height != 30 {
+			t.Errorf("%s: unexpected image %s %dx%d", path, img.Format, img.Width, img.Height)
+		}
+
+		if !bytes.Equal(img.Data, data) || img.Size() != len(data) {
+			t.Errorf("%s: unexpected image data", path)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		expected error
+	}{
+		{"/page.html", artwork.ErrInvalidImage},
+		{"/broken.png", artwork.ErrInvalidImage},
+		{"/missing.png", artwork.ErrNotFound},
+	}
+
+	for _, test := range tests {
+		if _, err := fetcher.Fetch(context.Background(), ts.URL+test.path); !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.path, test.expected, err)
+		}
+	}
+
+	fetcher.MaxBytes = int64(len(data) - 1)
+	if _, err := fetcher.Fetch(context.Background(), ts.URL+"/cover.png"); !errors.Is(err, artwork.ErrTooLarge) {
+		t.Errorf("expected a too large error, got %v", err)
+	}
+
+	fetcher.MaxBytes = 0
+	fetcher.MaxPixels = 40 * 29
+	if _, err := fetcher.Fetch(context.Background(), ts.URL+"/cover.png"); !errors.Is(err, artwork.ErrTooLarge) {
+		t.Errorf("expected a too large error, got %v", err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := &artwork.Image{Data: pngImage(t, 120, 80), Format: "png", Width: 120, Height: 80}
+
+	tests := []struct {
+		opts   artwork.ResizeOptions
+		format string
+		size   int
+	}{
+		{artwork.ResizeOptions{Size: 50, Format: "jpeg", Quality: 90}, "jpeg", 50},
+		{artwork.ResizeOptions{Size: 200}, "png", 200},
+		{artwork.ResizeOptions{}, "png", 80},
+	}
+
+	for _, test := range tests {
+		img, err := artwork.Resize(src, test.opts)
+		if err != nil {
+			t.Fatalf("%+v: unexpected error %v", test.opts, err)
+		}
+
+		if img.Format != test.format || img.Width != test.size || img.Height != test.size {
+			t.Errorf("%+v: unexpected image %s %dx%d", test.opts, img.Format, img.Width, img.Height)
+		}
+
+		decoded, err := img.Decode()
+		if err != nil {
+			t.Fatalf("%+v: unexpected error %v", test.opts, err)
+		}
+
+		if b := decoded.Bounds(); b.Dx() != test.size || b.Dy() != test.size {
+			t.Errorf("%+v: unexpected bounds %v", test.opts, b)
+		}
+	}
+
+	// The center of the cropped source is kept: the blue channel is constant
+	img, _ := artwork.Resize(src, artwork.ResizeOptions{Size: 10})
+	decoded, _ := img.Decode()
+	if _, _, b, _ := decoded.At(5, 5).RGBA(); b>>8 != 128 {
+		t.Errorf("unexpected blue channel %d", b>>8)
+	}
+
+	// The red channel follows the horizontal gradient of the cropped source
+	img, _ = artwork.Resize(src, artwork.ResizeOptions{Size: 40})
+	decoded, _ = img.Decode()
+	if r, _, _, _ := decoded.At(20, 20).RGBA(); r>>8 < 59 || r>>8 > 62 {
+		t.Errorf("unexpected red channel %d", r>>8)
+	}
+
+	if _, err := artwork.Resize(src, artwork.ResizeOptions{Format: "gif"}); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+
+	if _, err := artwork.Resize(src, artwork.ResizeOptions{Size: artwork.MaxSize + 1}); err == nil {
+		t.Errorf("expected an error for a size above the maximum")
+	}
+}
+
+// vorbisComment builds a Vorbis comment holding the fields
+func vorbisComment(fields ...string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, 6)
+	data = append(data, "vendor"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(fields)))
+
+	for _, f := range fields {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(f)))
+		data = append(data, f...)
+	}
+
+	return data
+}
+
+func TestParseVorbisComment(t *testing.T) {
+	data := vorbisComment("title=Anything Could Happen", "ALBUM=Halcyon", "Artist=Ellie Goulding", "ARTIST=Someone Else", "ALBUMARTIST=Ellie Goulding", "TRACKNUMBER=2")
+
+	tags, err := artwork.ParseVorbisComment(data)
+	expected := artwork.Tags{Album: "Halcyon", AlbumArtist: "Ellie Goulding", Artist: "Ellie Goulding", Title: "Anything Could Happen"}
+	if err != nil || *tags != expected {
+		t.Errorf("unexpected tags %+v %v", tags, err)
+	}
+
+	if _, err := artwork.ParseVorbisComment(data[:len(data)-4]); !errors.Is(err, artwork.ErrInvalidComment) {
+		t.Errorf("expected an invalid comment error, got %v", err)
+	}
+
+	if tags, _ := artwork.ParseVorbisComment(vorbisComment()); !tags.Empty() {
+		t.Errorf("expected empty tags, got %+v", tags)
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "track.flac")
+	if err := ioutil.WriteFile(path, []byte("original"), 0600); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The original is kept when writing fails
+	failure := errors.New("write failure")
+	err := artwork.ReplaceFile(path, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failure
+	})
+
+	if data, _ := ioutil.ReadFile(path); err != failure || string(data) != "original" {
+		t.Errorf("expected the original to be kept, got %q %v", data, err)
+	}
+
+	err = artwork.ReplaceFile(path, func(w io.Writer) error {
+		_, err := w.Write([]byte("replaced"))
+		return err
+	})
+
+	if data, _ := ioutil.ReadFile(path); err != nil || string(data) != "replaced" {
+		t.Errorf("expected the file to be replaced, got %q %v", data, err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the permissions to be kept, got %v %v", info.Mode(), err)
+	}
+
+	if files, _ := ioutil.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("expected the temporary files to be removed, got %d files", len(files))
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"Halcyon Days", "halcyon days!", 1, 1},
+		{"Simon & Garfunkel", "Simon and Garfunkel", 1, 1},
+		{"Beyonce", "Beyonc", 0.85, 0.9},
+		{"Goulding Ellie", "Ellie Goulding", 1, 1},
+		{"Stay", "Stay (Karaoke Version)", 0.4, 0.6},
+		{"Stay", "Umbrella", 0, 0.3},
+		{"", "Stay", 0, 0},
+	}
+
+	for _, test := range tests {
+		if s := artwork.Similarity(test.a, test.b); s < test.min || s > test.max {
+			t.Errorf("%q %q: expected a similarity between %.2f and %.2f, got %.2f", test.a, test.b, test.min, test.max, s)
+		}
+	}
+
+	if artwork.Normalize("  AC/DC -  Back in Black ") != "ac dc back in black" {
+		t.Errorf("unexpected normalized string %q", artwork.Normalize("  AC/DC -  Back in Black "))
+	}
+}
+
+func TestArtwork(t *testing.T) {
+	a := artwork.Artwork{Provider: "test"}
+	a.Add(artwork.Source{URL: "https://example.com/100.jpg?v=1", Width: 100, Height: 100})
+	a.Add(artwork.Source{URL: "https://example.com/600.png", Width: 600, Height: 600})
+	a.Add(artwork.Source{URL: "https://example.com/600.png"})
+	a.Add(artwork.Source{URL: ""})
+	a.Add(artwork.Source{URL: "https://example.com/300", Width: 300, Height: 300})
+
+	if len(a.Images) != 3 || a.Images[0].Format != "jpeg" || a.Images[1].Format != "png" || a.Images[2].Format != "" {
+		t.Errorf("unexpected images %+v", a.Images)
+	}
+
+	if src, ok := a.Largest(); !ok || src.Width != 600 {
+		t.Errorf("unexpected largest image %+v", src)
+	}
+
+	// The images of unknown dimensions are the original ones
+	a.Add(artwork.Source{URL: "https://example.com/original.jpg"})
+	a.Add(artwork.Source{URL: "https://example.com/200.jpg", Width: 200, Height: 200})
+	if src, ok := a.Largest(); !ok || src.URL != "https://example.com/original.jpg" {
+		t.Errorf("unexpected largest image %+v", src)
+	}
+
+	if _, ok := (&artwork.Artwork{}).Largest(); ok {
+		t.Errorf("expected no image")
+	}
+}
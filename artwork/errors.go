@@ -0,0 +1,137 @@
+// Package artwork holds what is shared by the itunesart, lastfmart and
+// spotifyart packages, like the errors they return and the retry policy of
+// their requests. The error values can be checked with errors.Is and the error
+// types with errors.As, whatever the service the error comes from.
+//
+// It also downloads the artworks found by the services with Fetch, which
+// returns the validated image bytes with their format and dimensions, and
+// normalizes them to a given square size and encoding with Resize. The Tags
+// read from the audio files by the id3art, flacart, mp4art and oggart packages
+// are defined here as well, like the Artwork the results of the services are
+// converted to, which keeps the dimensions of their images.
+package artwork
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error values shared by every service package
+var (
+	// ErrNotFound is returned when nothing matches the query or when the
+	// match has no artwork
+	ErrNotFound = errors.New("No artwork was found")
+
+	// ErrUnauthorized is returned when the credentials are missing, invalid
+	// or suspended
+	ErrUnauthorized = errors.New("Unauthorized request")
+
+	// ErrRateLimited is returned when the service refuses the request because
+	// of its rate limit, see RateLimitError
+	ErrRateLimited = errors.New("Rate limit exceeded")
+
+	// ErrUpstream is returned when the service failed to answer the request,
+	// see UpstreamError
+	ErrUpstream = errors.New("Upstream service error")
+)
+
+// The Error represents an error returned by a service with its own message,
+// which matches one of the error values with errors.Is
+type Error struct {
+	Err     error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound returns an error with the given message matching ErrNotFound
+func NotFound(message string) error {
+	return &Error{ErrNotFound, message}
+}
+
+// Unauthorized returns an error with the given message matching
+// ErrUnauthorized
+func Unauthorized(message string) error {
+	return &Error{ErrUnauthorized, message}
+}
+
+// The RateLimitError is returned when a service rate limits the requests, it
+// matches ErrRateLimited. RetryAfter is zero when the service did not tell
+// when to retry.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
+
+// Is reports if the target is ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// The UpstreamError is returned when a service failed to answer a request, it
+// matches ErrUpstream. StatusCode is the HTTP status of the response.
+type UpstreamError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Message
+}
+
+// Is reports if the target is ErrUpstream
+func (e *UpstreamError) Is(target error) bool {
+	return target == ErrUpstream
+}
+
+// ParseRetryAfter returns the delay of a Retry-After header, which holds either
+// a number of seconds or an HTTP date. It returns zero if the header is empty
+// or invalid.
+func ParseRetryAfter(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// ResponseError returns the error matching the HTTP status of a failed
+// response, with the given message. The message defaults to the status text.
+func ResponseError(resp *http.Response, message string) error {
+	if len(message) == 0 {
+		message = resp.Status
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return Unauthorized(message)
+	case http.StatusNotFound:
+		return NotFound(message)
+	case http.StatusTooManyRequests:
+		return &RateLimitError{ParseRetryAfter(resp.Header.Get("Retry-After")), message}
+	}
+
+	return &UpstreamError{resp.StatusCode, message}
+}
@@ -0,0 +1,206 @@
+package artwork
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the JPEG decoder
+	_ "image/png"  // register the PNG decoder
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// Limits of the default Fetcher
+const (
+	DefaultMaxBytes  = 10 << 20
+	DefaultMaxPixels = 5000 * 5000
+)
+
+// Error values returned when a downloaded artwork is refused
+var (
+	// ErrInvalidImage is returned when the artwork is not a valid JPEG or
+	// PNG image
+	ErrInvalidImage = errors.New("Invalid artwork image")
+
+	// ErrTooLarge is returned when the artwork exceeds the limits of the
+	// Fetcher
+	ErrTooLarge = errors.New("Artwork image is too large")
+)
+
+// The Image represents a downloaded artwork, with its encoded bytes
+type Image struct {
+	URL    string
+	Data   []byte
+	Format string // "jpeg" or "png"
+	Width  int
+	Height int
+}
+
+// MIMEType returns the media type of the image
+func (img *Image) MIMEType() string {
+	return "image/" + img.Format
+}
+
+// Size returns the size of the encoded image in bytes
+func (img *Image) Size() int {
+	return len(img.Data)
+}
+
+// The Fetcher downloads the artworks and validates them. The limits protect
+// from huge responses.
+type Fetcher struct {
+	// HTTPClient is used to execute the requests, http.DefaultClient is
+	// used when nil
+	HTTPClient *http.Client
+
+	// Retry is the policy applied to the requests failing with a transient
+	// error
+	Retry RetryPolicy
+
+	// MaxBytes is the maximum size of an encoded artwork, no limit is applied
+	// when it is zero
+	MaxBytes int64
+
+	// MaxPixels is the maximum number of pixels (width by height) of an
+	// artwork, no limit is applied when it is zero
+	MaxPixels int
+}
+
+// defaultFetcher is used by the package level Fetch
+var defaultFetcher = NewFetcher()
+
+// NewFetcher returns a Fetcher with the default limits and retry policy
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		HTTPClient: &http.Client{},
+		Retry:      DefaultRetryPolicy,
+		MaxBytes:   DefaultMaxBytes,
+		MaxPixels:  DefaultMaxPixels,
+	}
+}
+
+// Fetch downloads the artwork at the url with the default Fetcher
+func Fetch(ctx context.Context, url string) (*Image, error) {
+	return defaultFetcher.Fetch(ctx, url)
+}
+
+// Fetch downloads the artwork at the url, checks its Content-Type and decodes
+// it to make sure it is a valid JPEG or PNG image within the limits
+func (f *Fetcher) Fetch(ctx context.Context, url string) (*Image, error) {
+	var img *Image
+
+	err := f.Retry.Do(ctx, func() error {
+		var err error
+		img, err = f.fetch(ctx, url)
+		return err
+	})
+
+	return img, err
+}
+
+// Used to get the http client of the fetcher
+func (f *Fetcher) httpClient() *http.Client {
+	if f.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return f.HTTPClient
+}
+
+func (f *Fetcher) fetch(ctx context.Context, url string) (*Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ResponseError(resp, "")
+	}
+
+	if f.MaxBytes > 0 && resp.ContentLength > f.MaxBytes {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+
+	var body io.Reader = resp.Body
+	if f.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, f.MaxBytes+1)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.MaxBytes > 0 && int64(len(data)) > f.MaxBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, f.MaxBytes)
+	}
+
+	if err := checkContentType(resp.Header.Get("Content-Type"), data); err != nil {
+		return nil, err
+	}
+
+	img, err := f.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img.URL = url
+	return img, nil
+}
+
+// Used to verify the Content-Type of a response is a supported image. The
+// content is sniffed when the server did not tell its type.
+func checkContentType(contentType string, data []byte) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "", "application/octet-stream", "binary/octet-stream":
+		mediaType = http.DetectContentType(data)
+	}
+
+	switch mediaType {
+	case "image/jpeg", "image/jpg", "image/png":
+		return nil
+	}
+
+	return fmt.Errorf("%w: unexpected Content-Type %q", ErrInvalidImage, contentType)
+}
+
+// Used to decode the image, its dimensions are checked before decoding the
+// pixels
+func (f *Fetcher) decode(data []byte) (*Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	if format != "jpeg" && format != "png" {
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidImage, format)
+	}
+
+	if f.MaxPixels > 0 && config.Width*config.Height > f.MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrTooLarge, config.Width, config.Height)
+	}
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	return &Image{
+		Data:   data,
+		Format: format,
+		Width:  config.Width,
+		Height: config.Height,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package artwork
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ReplaceFile replaces the file at path by the content written by write. The
+// content is written into a temporary file of the same directory, which then
+// takes the place and the permissions of the original, so that the original
+// is left untouched when writing fails.
+func ReplaceFile(path string, write func(w io.Writer) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
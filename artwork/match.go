@@ -0,0 +1,86 @@
+package artwork
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize lowercases the string, replaces the punctuation by spaces and
+// collapses the spaces, so that the names returned by the services can be
+// compared with the queries. The ampersands are spelled out.
+func Normalize(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "&", " and ")
+
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}), " ")
+}
+
+// Similarity returns how close the two strings are once normalized, from 0 for
+// unrelated strings to 1 for identical ones. It is the highest of their edit
+// distance ratio, which tolerates typos, and of the share of words they have
+// in common, which tolerates reordered words. The extra words of a karaoke or
+// live version lower both of them.
+func Similarity(a string, b string) float64 {
+	a, b = Normalize(a), Normalize(b)
+	if a == b {
+		return 1
+	}
+
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	edit := 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+
+	return max(edit, tokenSimilarity(strings.Fields(a), strings.Fields(b)))
+}
+
+// Used to compute the number of rune insertions, deletions and substitutions
+// turning a into b
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// Used to compute the Dice coefficient of the sets of words, twice the number
+// of common words over the total number of words
+func tokenSimilarity(a []string, b []string) float64 {
+	setA, setB := map[string]bool{}, map[string]bool{}
+	for _, w := range a {
+		setA[w] = true
+	}
+
+	for _, w := range b {
+		setB[w] = true
+	}
+
+	common := 0
+	for w := range setA {
+		if setB[w] {
+			common++
+		}
+	}
+
+	return 2 * float64(common) / float64(len(setA)+len(setB))
+}
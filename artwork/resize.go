@@ -0,0 +1,208 @@
+package artwork
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// MaxSize is the largest Size accepted by Resize, the size of the largest
+// artworks of the services
+const MaxSize = 3000
+
+// The ResizeOptions describes the artwork produced by Resize
+type ResizeOptions struct {
+	// Size is the width and height of the produced square artwork, up to
+	// MaxSize. The artwork is only cropped to a square when it is zero.
+	Size int
+
+	// Format is the format of the produced artwork, "jpeg" or "png". The
+	// format of the source artwork is kept when it is empty.
+	Format string
+
+	// Quality is the JPEG quality, from 1 to 100. jpeg.DefaultQuality is used
+	// when it is zero.
+	Quality int
+}
+
+// Decode returns the decoded pixels of the image
+func (img *Image) Decode() (image.Image, error) {
+	m, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	return m, nil
+}
+
+// Resize returns a square copy of the artwork, with the size and encoding of
+// the options. Non square artworks are cropped around their center first, so
+// that the covers of any service can be normalized to the same dimensions.
+func Resize(img *Image, opts ResizeOptions) (*Image, error) {
+	format := opts.Format
+	if len(format) == 0 {
+		format = img.Format
+	}
+
+	if format != "jpeg" && format != "png" {
+		return nil, fmt.Errorf("Unsupported artwork format %q", format)
+	}
+
+	if opts.Size < 0 || opts.Size > MaxSize || opts.Quality < 0 || opts.Quality > 100 {
+		return nil, fmt.Errorf("Invalid resize options %+v", opts)
+	}
+
+	src, err := img.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	square := cropSquare(src)
+	size := opts.Size
+	if size == 0 {
+		size = square.Bounds().Dx()
+	}
+
+	dst := square
+	if size != square.Bounds().Dx() {
+		dst = scale(square, size)
+	}
+
+	buf := bytes.Buffer{}
+	switch format {
+	case "jpeg":
+		quality := opts.Quality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality})
+	case "png":
+		err = png.Encode(&buf, dst)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{
+		URL:    img.URL,
+		Data:   buf.Bytes(),
+		Format: format,
+		Width:  size,
+		Height: size,
+	}, nil
+}
+
+// Used to crop the largest square around the center of the image
+func cropSquare(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	origin := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), src, origin, draw.Src)
+
+	return dst
+}
+
+// Used to scale a square image to the given size, the pixels are resampled
+// with a tent filter, widened when shrinking so that every source pixel is
+// taken into account. The rows then the columns are resampled one at a time,
+// through an 8 bits image, to keep the memory used close to the size of the
+// images.
+func scale(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	taps := kernel(side, size)
+
+	tmp := image.NewRGBA(image.Rect(0, 0, size, side))
+	for y := 0; y < side; y++ {
+		resample(src.Pix[y*src.Stride:], 4, tmp.Pix[y*tmp.Stride:], 4, taps)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for x := 0; x < size; x++ {
+		resample(tmp.Pix[x*4:], tmp.Stride, dst.Pix[x*4:], dst.Stride, taps)
+	}
+
+	return dst
+}
+
+// The tap represents the source pixels, and their normalized weights, which
+// make one pixel of a resampled line
+type tap struct {
+	first   int
+	weights []float64
+}
+
+// Used to compute the taps resampling a line of n pixels to m pixels, the
+// pixels beyond the edges are replaced by the edge pixels
+func kernel(n int, m int) []tap {
+	ratio := float64(n) / float64(m)
+	support := math.Max(1, ratio)
+	taps := make([]tap, m)
+
+	for i := range taps {
+		center := (float64(i)+0.5)*ratio - 0.5
+		lo := int(math.Ceil(center - support))
+		hi := int(math.Floor(center + support))
+
+		t := tap{first: min(max(lo, 0), n-1)}
+		t.weights = make([]float64, min(max(hi, 0), n-1)-t.first+1)
+
+		var total float64
+		for j := lo; j <= hi; j++ {
+			w := 1 - math.Abs(float64(j)-center)/support
+			if w <= 0 {
+				continue
+			}
+
+			t.weights[min(max(j, 0), n-1)-t.first] += w
+			total += w
+		}
+
+		for k := range t.weights {
+			t.weights[k] /= total
+		}
+
+		taps[i] = t
+	}
+
+	return taps
+}
+
+// Used to resample a line of pixels of 4 channels from src, separated by
+// srcStep bytes, into dst, separated by dstStep bytes
+func resample(src []uint8, srcStep int, dst []uint8, dstStep int, taps []tap) {
+	for i, t := range taps {
+		var sum [4]float64
+		for k, w := range t.weights {
+			p := src[(t.first+k)*srcStep:]
+			for c := 0; c < 4; c++ {
+				sum[c] += w * float64(p[c])
+			}
+		}
+
+		for c := 0; c < 4; c++ {
+			dst[i*dstStep+c] = clamp(sum[c])
+		}
+	}
+}
+
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+
+	return uint8(v + 0.5)
+}
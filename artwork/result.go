@@ -0,0 +1,97 @@
+package artwork
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Names of the services, as set in the Provider of their artworks
+const (
+	ItunesName  = "itunes"
+	LastFmName  = "lastfm"
+	SpotifyName = "spotify"
+)
+
+// The Source represents one of the images of an artwork, as returned by a
+// service. The dimensions are zero and the format empty when the service does
+// not tell them.
+type Source struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Format string `json:"format,omitempty"`
+}
+
+// The Artwork represents the images of an album, track or artist artwork with
+// their dimensions, and where they come from. It is shared by the itunesart,
+// lastfmart and spotifyart packages, which convert their results to it.
+type Artwork struct {
+	// Provider is the name of the service the artwork comes from
+	Provider string `json:"provider"`
+
+	// ID is the id of the matched album, track or artist in the service, when
+	// it is known
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Artist string `json:"artist,omitempty"`
+
+	// Images are the images of the artwork, from the smallest to the largest
+	// when the dimensions are known
+	Images []Source `json:"images"`
+
+	// Retrieved is the time the artwork was looked up
+	Retrieved time.Time `json:"retrieved"`
+}
+
+// Largest returns the largest image of the artwork. The images of unknown
+// dimensions are taken as larger than the others, as the services only omit
+// the dimensions of their original images, the last one wins among them. ok
+// is false when the artwork has no image.
+func (a Artwork) Largest() (src Source, ok bool) {
+	for _, img := range a.Images {
+		area, largest := img.Width*img.Height, src.Width*src.Height
+		if !ok || area == 0 || (largest > 0 && area >= largest) {
+			src, ok = img, true
+		}
+	}
+
+	return src, ok
+}
+
+// Add appends the image to the artwork, unless its url is empty or already
+// part of it. The format is guessed from the extension of the url when empty.
+func (a *Artwork) Add(src Source) {
+	if len(src.URL) == 0 {
+		return
+	}
+
+	for _, img := range a.Images {
+		if img.URL == src.URL {
+			return
+		}
+	}
+
+	if len(src.Format) == 0 {
+		src.Format = FormatOf(src.URL)
+	}
+
+	a.Images = append(a.Images, src)
+}
+
+// FormatOf guesses the format of an image, jpeg or png, from the extension of
+// its url. It is empty when the extension is unknown.
+func FormatOf(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+
+	switch strings.ToLower(path.Ext(url)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	}
+
+	return ""
+}
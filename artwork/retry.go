@@ -0,0 +1,108 @@
+package artwork
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// The RetryPolicy describes how the requests failing with a transient error
+// are retried. The delay between two attempts grows exponentially from
+// BaseDelay, unless the service told when to retry with a RateLimitError.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// The requests are not retried when it is lower than 2.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, it is doubled for each
+	// of the next ones
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between two attempts. The requests are not
+	// retried when the service asks to wait longer than it. No cap is applied
+	// when it is zero.
+	MaxDelay time.Duration
+
+	// Jitter randomizes the delays by up to this fraction of their value,
+	// between 0 and 1, to spread the retries of concurrent requests
+	Jitter float64
+}
+
+// DefaultRetryPolicy is the policy of the clients returned by the NewClient
+// functions of the service packages
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.2,
+}
+
+// NoRetry is a policy executing every request only once
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// Retryable reports if the error is transient, so that the request may
+// succeed if retried: rate limits, server errors and network timeouts
+func Retryable(err error) bool {
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
+	var upErr *UpstreamError
+	if errors.As(err, &upErr) {
+		return upErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Delay returns the delay to wait before the given retry, starting at 1, of a
+// request which failed with err
+func (p RetryPolicy) Delay(retry int, err error) time.Duration {
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) && rateErr.RetryAfter > 0 {
+		return rateErr.RetryAfter
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay == 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+
+	return d
+}
+
+// Do calls fn until it succeeds, fails with an error which is not Retryable or
+// the attempts are exhausted, and returns its last error. It stops waiting
+// when ctx is done.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		var rateErr *RateLimitError
+		if errors.As(err, &rateErr) && p.MaxDelay > 0 && rateErr.RetryAfter > p.MaxDelay {
+			return err
+		}
+
+		timer := time.NewTimer(p.Delay(attempt, err))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
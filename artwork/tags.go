@@ -0,0 +1,87 @@
+package artwork
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidComment is returned when a Vorbis comment is truncated
+var ErrInvalidComment = errors.New("Invalid Vorbis comment")
+
+// The Tags represents the fields of the tags of an audio file which identify
+// its artwork. The fields are empty when the tags do not have them.
+type Tags struct {
+	Album       string
+	AlbumArtist string
+	Artist      string
+	Title       string
+}
+
+// Empty reports if none of the fields are set
+func (t *Tags) Empty() bool {
+	return len(t.Album) == 0 && len(t.AlbumArtist) == 0 && len(t.Artist) == 0 && len(t.Title) == 0
+}
+
+// ParseVorbisComment returns the tags of a Vorbis comment, as found in the
+// FLAC, Ogg Vorbis and Opus files. The data starts with the vendor string
+// length, the packet type and the framing bit of Ogg Vorbis are excluded.
+// The first value of the fields is kept when they are repeated.
+func ParseVorbisComment(data []byte) (*Tags, error) {
+	t := &Tags{}
+
+	field := func() ([]byte, bool) {
+		if len(data) < 4 {
+			return nil, false
+		}
+
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return nil, false
+		}
+
+		f := data[4 : 4+n]
+		data = data[4+n:]
+		return f, true
+	}
+
+	// The vendor string
+	if _, ok := field(); !ok || len(data) < 4 {
+		return nil, ErrInvalidComment
+	}
+
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	for i := uint32(0); i < count; i++ {
+		f, ok := field()
+		if !ok {
+			return nil, ErrInvalidComment
+		}
+
+		key, value, ok := strings.Cut(string(f), "=")
+		if !ok {
+			continue
+		}
+
+		var dst *string
+		switch strings.ToUpper(key) {
+		case "ALBUM":
+			dst = &t.Album
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			dst = &t.AlbumArtist
+		case "ARTIST":
+			dst = &t.Artist
+		case "TITLE":
+			dst = &t.Title
+		default:
+			continue
+		}
+
+		if len(*dst) == 0 {
+			*dst = strings.TrimSpace(value)
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,302 @@
+package coverart
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of lookups, used to build the cache keys
+const (
+	AlbumKind  = "album"
+	TrackKind  = "track"
+	ArtistKind = "artist"
+)
+
+// Kinds of the cache keys of the Searcher and Verifier calls
+const (
+	candidatesKind = "candidates"
+	verifyKind     = "verify"
+)
+
+// The Cache represents a store of the results of previous lookups. Only the
+// successful lookups are stored.
+type Cache interface {
+	// Get returns the result stored for the key, if any and not expired
+	Get(key string) (Result, bool)
+	// Set stores the result for the key
+	Set(key string, res Result)
+}
+
+// The CandidateCache represents a Cache which stores the candidates of the
+// searches as well. MemoryCache and FileCache implement it, the searches are
+// not cached by the other caches.
+type CandidateCache interface {
+	Cache
+	// GetCandidates returns the candidates stored for the key, if any and not
+	// expired
+	GetCandidates(key string) ([]Candidate, bool)
+	// SetCandidates stores the candidates for the key
+	SetCandidates(key string, candidates []Candidate)
+}
+
+// CacheKey builds the key of a lookup from the provider name, the kind of
+// lookup and its normalized query: case, surrounding and repeated spaces are
+// ignored
+func CacheKey(provider string, kind string, query ...string) string {
+	parts := []string{provider, kind}
+
+	for _, q := range query {
+		parts = append(parts, strings.Join(strings.Fields(strings.ToLower(q)), " "))
+	}
+
+	return strings.Join(parts, "\x1f")
+}
+
+// The cachedProvider consults its cache before doing the lookups of the
+// wrapped Provider
+type cachedProvider struct {
+	provider Provider
+	cache    Cache
+}
+
+// The cachedSearcher is a cachedProvider of a Searcher, which caches the
+// searches and the verifications as well
+type cachedSearcher struct {
+	*cachedProvider
+	searcher Searcher
+}
+
+// Cached returns a Provider which consults the cache before doing any lookup
+// with p, and stores every result found by p in it. When p implements
+// Searcher, so does the returned Provider, as well as Verifier: the candidates
+// are cached when c is a CandidateCache, and the verified results are cached
+// along with the others.
+func Cached(p Provider, c Cache) Provider {
+	cached := &cachedProvider{p, c}
+	if s, ok := p.(Searcher); ok {
+		return &cachedSearcher{cached, s}
+	}
+
+	return cached
+}
+
+func (p *cachedProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *cachedProvider) Album(ctx context.Context, album string, artist string) (Result, error) {
+	return p.lookup(CacheKey(p.Name(), AlbumKind, album, artist), func() (Result, error) {
+		return p.provider.Album(ctx, album, artist)
+	})
+}
+
+func (p *cachedProvider) Track(ctx context.Context, track string, artist string) (Result, error) {
+	return p.lookup(CacheKey(p.Name(), TrackKind, track, artist), func() (Result, error) {
+		return p.provider.Track(ctx, track, artist)
+	})
+}
+
+func (p *cachedProvider) Artist(ctx context.Context, artist string) (Result, error) {
+	return p.lookup(CacheKey(p.Name(), ArtistKind, artist), func() (Result, error) {
+		return p.provider.Artist(ctx, artist)
+	})
+}
+
+func (p *cachedProvider) lookup(key string, fn func() (Result, error)) (Result, error) {
+	if res, ok := p.cache.Get(key); ok {
+		return res, nil
+	}
+
+	res, err := fn()
+	if err != nil {
+		return Result{}, err
+	}
+
+	p.cache.Set(key, res)
+	return res, nil
+}
+
+func (p *cachedSearcher) AlbumCandidates(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	return p.search(CacheKey(p.Name(), candidatesKind, AlbumKind, album, artist, strconv.Itoa(limit)), func() ([]Candidate, error) {
+		return p.searcher.AlbumCandidates(ctx, album, artist, limit)
+	})
+}
+
+func (p *cachedSearcher) TrackCandidates(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	return p.search(CacheKey(p.Name(), candidatesKind, TrackKind, track, artist, strconv.Itoa(limit)), func() ([]Candidate, error) {
+		return p.searcher.TrackCandidates(ctx, track, artist, limit)
+	})
+}
+
+func (p *cachedSearcher) ArtistCandidates(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	return p.search(CacheKey(p.Name(), candidatesKind, ArtistKind, artist, strconv.Itoa(limit)), func() ([]Candidate, error) {
+		return p.searcher.ArtistCandidates(ctx, artist, limit)
+	})
+}
+
+// Verify checks the artworks of the result with the wrapped provider, when it
+// implements Verifier. The verified artworks are cached by their urls, which
+// are case sensitive unlike the queries.
+func (p *cachedSearcher) Verify(ctx context.Context, res Result) Result {
+	v, ok := p.provider.(Verifier)
+	if !ok {
+		return res
+	}
+
+	key := strings.Join([]string{CacheKey(p.Name(), verifyKind), res.Provider, res.Small, res.Medium, res.Large, res.Default}, "\x1f")
+	verified, ok := p.cache.Get(key)
+	if !ok {
+		// The artworks are dropped when the checks are interrupted, which must
+		// not outlive the context
+		verified = v.Verify(ctx, res)
+		if ctx.Err() == nil {
+			p.cache.Set(key, verified)
+		}
+	}
+
+	verified.Confidence = res.Confidence
+	return verified
+}
+
+func (p *cachedSearcher) search(key string, fn func() ([]Candidate, error)) ([]Candidate, error) {
+	c, ok := p.cache.(CandidateCache)
+	if !ok {
+		return fn()
+	}
+
+	if candidates, ok := c.GetCandidates(key); ok {
+		return candidates, nil
+	}
+
+	candidates, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetCandidates(key, candidates)
+	return candidates, nil
+}
+
+type memoryEntry struct {
+	key        string
+	res        Result
+	candidates []Candidate
+	expires    time.Time
+}
+
+// The MemoryCache is an in-memory Cache holding a bounded number of results.
+// The least recently used results are evicted first. It is safe for
+// concurrent use.
+type MemoryCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewMemoryCache returns a MemoryCache holding at most size results, each of
+// them for the ttl duration. Results never expire when ttl is zero.
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Get returns the result stored for the key
+func (c *MemoryCache) Get(key string) (Result, bool) {
+	entry, ok := c.get(key)
+	if !ok {
+		return Result{}, false
+	}
+
+	return entry.res, true
+}
+
+// GetCandidates returns the candidates stored for the key
+func (c *MemoryCache) GetCandidates(key string) ([]Candidate, bool) {
+	entry, ok := c.get(key)
+	if !ok {
+		return nil, false
+	}
+
+	return entry.candidates, true
+}
+
+// Set stores the result for the key, evicting the least recently used result
+// when the cache is full
+func (c *MemoryCache) Set(key string, res Result) {
+	c.set(&memoryEntry{key: key, res: res})
+}
+
+// SetCandidates stores the candidates for the key, they count as a single
+// result
+func (c *MemoryCache) SetCandidates(key string, candidates []Candidate) {
+	c.set(&memoryEntry{key: key, candidates: candidates})
+}
+
+// Used to get the entry of the key, expired entries are removed
+func (c *MemoryCache) get(key string) (*memoryEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+// Used to store the entry, evicting the least recently used entries when the
+// cache is full
+func (c *MemoryCache) set(entry *memoryEntry) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[entry.key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*memoryEntry).key)
+	}
+}
+
+// Len returns the number of results held by the cache
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
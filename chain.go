@@ -0,0 +1,105 @@
+package coverart
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// The FallbackFunc decides if a Chain should move on to its next provider
+// after the given lookup error
+type FallbackFunc func(err error) bool
+
+// The Chain tries its providers one after the other, in the configured order,
+// and returns the first artwork found. It only moves on to the next provider
+// when the fallback rule of the current one allows it.
+type Chain struct {
+	Providers []Provider
+
+	// Fallback holds the rules of the providers, by provider name. The
+	// DefaultFallback rule is used for the providers without one.
+	Fallback map[string]FallbackFunc
+}
+
+// NewChain returns a Chain trying the given providers in order
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{
+		Providers: providers,
+		Fallback:  map[string]FallbackFunc{},
+	}
+}
+
+// DefaultFallback moves on to the next provider when the artwork was not found
+// or when the lookup failed because of a transient error: rate limit, server
+// error or network timeout
+func DefaultFallback(err error) bool {
+	return errors.Is(err, ErrNotFound) || artwork.Retryable(err)
+}
+
+// AlwaysFallback moves on to the next provider whatever the error
+func AlwaysFallback(err error) bool {
+	return true
+}
+
+// Name returns the names of the providers of the chain. A Chain implements the
+// Provider interface, the Result keeps the name of the provider it comes from.
+func (c *Chain) Name() string {
+	names := make([]string, len(c.Providers))
+	for i, p := range c.Providers {
+		names[i] = p.Name()
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Album gets the album artwork from the first provider which has it
+func (c *Chain) Album(ctx context.Context, album string, artist string) (Result, error) {
+	return c.lookup(ctx, func(p Provider) (Result, error) {
+		return p.Album(ctx, album, artist)
+	})
+}
+
+// Track gets the track artwork from the first provider which has it
+func (c *Chain) Track(ctx context.Context, track string, artist string) (Result, error) {
+	return c.lookup(ctx, func(p Provider) (Result, error) {
+		return p.Track(ctx, track, artist)
+	})
+}
+
+// Artist gets the artist artwork from the first provider which has it
+func (c *Chain) Artist(ctx context.Context, artist string) (Result, error) {
+	return c.lookup(ctx, func(p Provider) (Result, error) {
+		return p.Artist(ctx, artist)
+	})
+}
+
+// Tries the lookup on every provider in order, the error of the last provider
+// tried is returned when none of them succeeded
+func (c *Chain) lookup(ctx context.Context, fn func(Provider) (Result, error)) (Result, error) {
+	err := errors.New("No provider was configured")
+
+	for _, p := range c.Providers {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Result{}, ctxErr
+		}
+
+		var res Result
+		res, err = fn(p)
+		if err == nil {
+			return res, nil
+		}
+
+		fallback, ok := c.Fallback[p.Name()]
+		if !ok || fallback == nil {
+			fallback = DefaultFallback
+		}
+
+		if !fallback(err) {
+			return Result{}, err
+		}
+	}
+
+	return Result{}, err
+}
@@ -0,0 +1,398 @@
+// Command coverart looks up the artworks of albums, tracks and artists with
+// the coverart providers, and downloads them.
+//
+// Usage:
+//
+//	coverart [flags] album <album> <artist>
+//	coverart [flags] track <track> <artist>
+//	coverart [flags] artist <artist>
+//	coverart [flags] fetch [-o file] [-size n] [-format jpeg|png] album|track|artist <query>...
+//	coverart [flags] scan [-workers n] [-state file] [-skip-not-found] [-embed] [-size n] [-format jpeg|png] <dir>
+//	coverart [flags] serve [-addr host:port] [-cache n] [-cache-ttl duration] [-max-age duration]
+//
+// The scan command fills in the missing album artworks of a music directory,
+// see the library package. The serve command exposes the providers as an HTTP
+// service, see coverart.Handler.
+//
+// The providers are tried in the order of the -provider flag until one has the
+// artwork. The credentials are read from the LASTFM_APIKEY, SPOTIFY_CLIENTID
+// and SPOTIFY_CLIENTSECRET environment variables, unless given as flags. Last.fm
+// is left out with a warning when its API Key can not be checked. With the
+// -min-score flag, the matches are verified against the query and rejected
+// when they are not similar enough, see coverart.Matcher.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/piraveen/go-coverart"
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/lastfmart"
+	"github.com/piraveen/go-coverart/library"
+	"github.com/piraveen/go-coverart/spotifyart"
+)
+
+// Exit codes of the command
+const (
+	exitError = 1
+	exitUsage = 2
+)
+
+// errUsage is returned when the command line is invalid
+var errUsage = errors.New("Invalid arguments")
+
+// The unavailableError represents a provider whose credentials could not be
+// checked, it is left out with a warning instead of failing the command
+type unavailableError struct {
+	name string
+	err  error
+}
+
+func (e *unavailableError) Error() string {
+	return fmt.Sprintf("%s is not used: %v", e.name, e.err)
+}
+
+const usage = `Usage:
+  coverart [flags] album <album> <artist>
+  coverart [flags] track <track> <artist>
+  coverart [flags] artist <artist>
+  coverart [flags] fetch [-o file] [-size n] [-format jpeg|png] album|track|artist <query>...
+  coverart [flags] scan [-workers n] [-state file] [-skip-not-found] [-embed] [-size n] [-format jpeg|png] <dir>
+  coverart [flags] serve [-addr host:port] [-cache n] [-cache-ttl duration] [-max-age duration]
+
+Flags:
+`
+
+// The config represents the flags shared by every command
+type config struct {
+	providers     string
+	json          bool
+	timeout       time.Duration
+	lastFmKey     string
+	spotifyId     string
+	spotifySecret string
+	minScore      float64
+}
+
+// Constructors of the providers selected with the -provider flag, the requests
+// they make are bound to the context of the command
+var providers = map[string]func(ctx context.Context, cfg *config) (coverart.Provider, error){
+	coverart.ItunesName: func(ctx context.Context, cfg *config) (coverart.Provider, error) {
+		return coverart.Itunes(), nil
+	},
+	coverart.LastFmName: func(ctx context.Context, cfg *config) (coverart.Provider, error) {
+		if len(cfg.lastFmKey) == 0 {
+			return nil, errors.New("The Last.fm API Key is not set, see LASTFM_APIKEY")
+		}
+
+		p, err := coverart.NewLastFmContext(ctx, lastfmart.NewClient(cfg.lastFmKey))
+		if err != nil {
+			return nil, &unavailableError{"Last.fm", err}
+		}
+
+		return p, nil
+	},
+	coverart.SpotifyName: func(ctx context.Context, cfg *config) (coverart.Provider, error) {
+		if len(cfg.spotifyId) == 0 || len(cfg.spotifySecret) == 0 {
+			return nil, errors.New("The Spotify credentials are not set, see SPOTIFY_CLIENTID and SPOTIFY_CLIENTSECRET")
+		}
+
+		return coverart.NewSpotify(spotifyart.NewClient(cfg.spotifyId, cfg.spotifySecret)), nil
+	},
+}
+
+// Used to build the provider of the -provider flag, every configured provider
+// is used when it is empty. The providers which are unavailable are reported
+// to stderr and left out.
+func (cfg *config) provider(ctx context.Context, stderr io.Writer) (coverart.Provider, error) {
+	names := strings.Split(cfg.providers, ",")
+	if len(cfg.providers) == 0 {
+		names = []string{coverart.ItunesName}
+		if len(cfg.spotifyId) > 0 && len(cfg.spotifySecret) > 0 {
+			names = append(names, coverart.SpotifyName)
+		}
+
+		if len(cfg.lastFmKey) > 0 {
+			names = append(names, coverart.LastFmName)
+		}
+	}
+
+	chain := coverart.NewChain()
+	for _, name := range names {
+		newProvider, ok := providers[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown provider %q", name)
+		}
+
+		p, err := newProvider(ctx, cfg)
+		var unavailable *unavailableError
+		if errors.As(err, &unavailable) {
+			fmt.Fprintln(stderr, "coverart: warning:", err)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		chain.Providers = append(chain.Providers, p)
+	}
+
+	var p coverart.Provider = chain
+	if len(chain.Providers) == 1 {
+		p = chain.Providers[0]
+	}
+
+	if cfg.minScore > 0 {
+		matcher := coverart.NewMatcher(p)
+		matcher.Threshold = cfg.minScore
+		return matcher, nil
+	}
+
+	return p, nil
+}
+
+// Used to run the lookup of the given kind with the arguments
+func lookup(ctx context.Context, p coverart.Provider, kind string, args []string) (coverart.Result, error) {
+	switch {
+	case kind == "album" && len(args) == 2:
+		return p.Album(ctx, args[0], args[1])
+	case kind == "track" && len(args) == 2:
+		return p.Track(ctx, args[0], args[1])
+	case kind == "artist" && len(args) == 1:
+		return p.Artist(ctx, args[0])
+	}
+
+	return coverart.Result{}, errUsage
+}
+
+// Used to print the result, as JSON or as the url of the largest artwork
+func printResult(w io.Writer, res coverart.Result, asJSON bool) error {
+	if !asJSON {
+		_, err := fmt.Fprintln(w, res.URL())
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// Used to download the artwork of a lookup into a file
+func fetch(ctx context.Context, p coverart.Provider, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	output := flags.String("o", "", "`file` written, cover.jpg or cover.png by default, - for the standard output")
+	size := flags.Int("size", 0, "size of the square artwork, the artwork is not resized when zero")
+	format := flags.String("format", "", "format of the artwork, jpeg or png, the downloaded format is kept when empty")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 || *size < 0 || *size > artwork.MaxSize {
+		return errUsage
+	}
+
+	res, err := lookup(ctx, p, flags.Arg(0), flags.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	img, err := coverart.Fetch(ctx, res)
+	if err != nil {
+		return err
+	}
+
+	if *size > 0 || len(*format) > 0 {
+		img, err = artwork.Resize(img, artwork.ResizeOptions{Size: *size, Format: *format})
+		if err != nil {
+			return err
+		}
+	}
+
+	switch *output {
+	case "-":
+		_, err = stdout.Write(img.Data)
+		return err
+	case "":
+		*output = "cover.jpg"
+		if img.Format == "png" {
+			*output = "cover.png"
+		}
+	}
+
+	return ioutil.WriteFile(*output, img.Data, 0644)
+}
+
+// Used to fill in the missing artworks of a music directory, the outcome of
+// every album is printed
+func scan(ctx context.Context, p coverart.Provider, args []string, stdout io.Writer) error {
+	scanner := library.NewScanner(p)
+
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.IntVar(&scanner.Workers, "workers", scanner.Workers, "number of albums processed concurrently")
+	flags.StringVar(&scanner.StateFile, "state", "", "`file` recording the processed albums, so that the scan can be resumed")
+	flags.BoolVar(&scanner.SkipNotFound, "skip-not-found", false, "skip the albums not found by the previous scans instead of looking them up again")
+	flags.BoolVar(&scanner.Embed, "embed", false, "embed the artworks into the audio files as well")
+	size := flags.Int("size", 0, "size of the square artworks, the artworks are not resized when zero")
+	format := flags.String("format", "", "format of the artworks, jpeg or png, the downloaded format is kept when empty")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || *size < 0 || *size > artwork.MaxSize {
+		return errUsage
+	}
+
+	if *size > 0 || len(*format) > 0 {
+		scanner.Resize = &artwork.ResizeOptions{Size: *size, Format: *format}
+	}
+
+	scanner.Progress = func(r library.Report) {
+		if r.Err != nil {
+			fmt.Fprintf(stdout, "%s\t%s\t%v\n", r.Status, r.Album.Dir, r.Err)
+		} else {
+			fmt.Fprintf(stdout, "%s\t%s\n", r.Status, r.Album.Dir)
+		}
+	}
+
+	summary, err := scanner.Scan(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%d written, %d not found, %d failed, %d with an artwork, %d resumed\n",
+		summary[library.Written], summary[library.NotFound], summary[library.Failed], summary[library.HasArtwork], summary[library.Resumed])
+	return nil
+}
+
+// Used to serve the /album, /track and /artist endpoints until ctx is done,
+// every request is bound to the timeout
+func serve(ctx context.Context, p coverart.Provider, args []string, timeout time.Duration, stderr io.Writer) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	addr := flags.String("addr", "localhost:8080", "`address` the service listens on")
+	cacheSize := flags.Int("cache", 10000, "number of results kept in memory, the results are not cached when zero")
+	cacheTTL := flags.Duration("cache-ttl", 24*time.Hour, "duration the results are kept in memory")
+	maxAge := flags.Duration("max-age", 24*time.Hour, "duration the responses can be cached by the clients")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errUsage
+	}
+
+	if *cacheSize > 0 {
+		p = coverart.Cached(p, coverart.NewMemoryCache(*cacheSize, *cacheTTL))
+	}
+
+	handler := coverart.NewHandler(p)
+	handler.MaxAge = *maxAge
+	handler.Timeout = timeout
+
+	server := &http.Server{Addr: *addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(stderr, "coverart: serving %s on %s\n", p.Name(), *addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Used to run the command with the arguments, it returns the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	cfg := &config{}
+	flags := flag.NewFlagSet("coverart", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&cfg.providers, "provider", "", "comma separated `names` of the providers tried in order: itunes, spotify, lastfm. Every configured provider is used by default")
+	flags.BoolVar(&cfg.json, "json", false, "print the results as JSON")
+	flags.DurationVar(&cfg.timeout, "timeout", 30*time.Second, "timeout of the command, none when zero. The scan command has no timeout unless set, the timeout of the serve command applies to every request")
+	flags.Float64Var(&cfg.minScore, "min-score", 0, "reject the matches whose similarity with the query is below this `score`, from 0 to 1. The matches are not verified when zero")
+	flags.StringVar(&cfg.lastFmKey, "lastfm-key", os.Getenv("LASTFM_APIKEY"), "Last.fm API Key")
+	flags.StringVar(&cfg.spotifyId, "spotify-id", os.Getenv("SPOTIFY_CLIENTID"), "Spotify Client Id")
+	flags.StringVar(&cfg.spotifySecret, "spotify-secret", os.Getenv("SPOTIFY_CLIENTSECRET"), "Spotify Client Secret")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	command, args := flags.Arg(0), flags.Args()
+	switch command {
+	case "album", "track", "artist", "fetch", "scan", "serve":
+		args = args[1:]
+	default:
+		flags.Usage()
+		return exitUsage
+	}
+
+	timeout := cfg.timeout
+	switch command {
+	case "scan":
+		timeout = 0
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "timeout" {
+				timeout = cfg.timeout
+			}
+		})
+	case "serve":
+		timeout = 0
+	}
+
+	// An interrupted scan is resumed by the next one with the same state file
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	p, err := cfg.provider(ctx, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "coverart:", err)
+		return exitError
+	}
+
+	switch command {
+	case "fetch":
+		err = fetch(ctx, p, args, stdout)
+	case "scan":
+		err = scan(ctx, p, args, stdout)
+	case "serve":
+		err = serve(ctx, p, args, cfg.timeout, stderr)
+	default:
+		var res coverart.Result
+		if res, err = lookup(ctx, p, command, args); err == nil {
+			err = printResult(stdout, res, cfg.json)
+		}
+	}
+
+	switch {
+	case errors.Is(err, errUsage):
+		flags.Usage()
+		return exitUsage
+	case err != nil:
+		fmt.Fprintln(stderr, "coverart:", err)
+		return exitError
+	}
+
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/piraveen/go-coverart"
+	"github.com/piraveen/go-coverart/artwork"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeProvider returns the url for the album and track lookups only
+type fakeProvider struct {
+	url string
+}
+
+func (p fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p fakeProvider) Album(ctx context.Context, album string, artist string) (coverart.Result, error) {
+	return coverart.Result{Provider: "fake", Large: p.url}, nil
+}
+
+func (p fakeProvider) Track(ctx context.Context, track string, artist string) (coverart.Result, error) {
+	return coverart.Result{Provider: "fake", Large: p.url}, nil
+}
+
+func (p fakeProvider) Artist(ctx context.Context, artist string) (coverart.Result, error) {
+	return coverart.Result{}, artwork.NotFound("No artist artwork")
+}
+
+func useFake(t *testing.T, url string) {
+	providers["fake"] = func(ctx context.Context, cfg *config) (coverart.Provider, error) {
+		return fakeProvider{url}, nil
+	}
+
+	t.Cleanup(func() { delete(providers, "fake") })
+}
+
+func TestUsage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"unknown"},
+		{"-provider", "fake", "album", "halcyon days"},
+		{"-provider", "fake", "fetch"},
+		{"-provider", "fake", "fetch", "-size", "3001", "album", "halcyon days", "ellie goulding"},
+		{"-provider", "fake", "scan", "-size", "-1", "."},
+		{"-provider", "fake", "serve", "unexpected"},
+		{"-unknown-flag", "album"},
+	}
+
+	useFake(t, "http://example.com/cover.jpg")
+	for _, args := range tests {
+		stderr := bytes.Buffer{}
+		if code := run(args, ioutil.Discard, &stderr); code != exitUsage || !strings.Contains(stderr.String(), "Usage") {
+			t.Errorf("%q: expected the usage, got %d %q", args, code, stderr.String())
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	useFake(t, "http://example.com/cover.jpg")
+
+	stdout := bytes.Buffer{}
+	if code := run([]string{"-provider", "fake", "album", "halcyon days", "ellie goulding"}, &stdout, ioutil.Discard); code != 0 || stdout.String() != "http://example.com/cover.jpg\n" {
+		t.Errorf("unexpected output %d %q", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-provider", "fake", "-json", "track", "figure 8", "ellie goulding"}, &stdout, ioutil.Discard); code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+
+	res := coverart.Result{}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil || res.Large != "http://example.com/cover.jpg" {
+		t.Errorf("unexpected JSON output %q %v", stdout.String(), err)
+	}
+
+	stderr := bytes.Buffer{}
+	if code := run([]string{"-provider", "fake", "artist", "ellie goulding"}, ioutil.Discard, &stderr); code != exitError || !strings.Contains(stderr.String(), "No artist artwork") {
+		t.Errorf("expected the lookup error, got %d %q", code, stderr.String())
+	}
+}
+
+func TestProviderFlag(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-provider", "unknown"}, "Unknown provider"},
+		{[]string{"-provider", "lastfm", "-lastfm-key", ""}, "LASTFM_APIKEY"},
+		{[]string{"-provider", "itunes,spotify", "-spotify-id", ""}, "SPOTIFY_CLIENTID"},
+	}
+
+	for _, test := range tests {
+		stderr := bytes.Buffer{}
+		args := append(test.args, "artist", "ellie goulding")
+
+		if code := run(args, ioutil.Discard, &stderr); code != exitError || !strings.Contains(stderr.String(), test.expected) {
+			t.Errorf("%q: expected a provider error, got %d %q", test.args, code, stderr.String())
+		}
+	}
+}
+
+func TestUnavailableProvider(t *testing.T) {
+	useFake(t, "http://example.com/cover.jpg")
+
+	// The Last.fm API Key is checked within the context of the command, the
+	// providers which fail the check are left out
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stderr := bytes.Buffer{}
+	cfg := &config{providers: "lastfm,fake", lastFmKey: "key"}
+	p, err := cfg.provider(ctx, &stderr)
+	if err != nil || p.Name() != "fake" {
+		t.Fatalf("unexpected provider %v %v", p, err)
+	}
+
+	if !strings.Contains(stderr.String(), "warning: Last.fm is not used") {
+		t.Errorf("expected a warning, got %q", stderr.String())
+	}
+}
+
+func TestMinScore(t *testing.T) {
+	cfg := &config{providers: "itunes", minScore: 0.8}
+	p, err := cfg.provider(context.Background(), ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if m, ok := p.(*coverart.Matcher); !ok || m.Threshold != 0.8 || m.Name() != "itunes" {
+		t.Errorf("expected a matcher of the provider, got %#v", p)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	buf := bytes.Buffer{}
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	useFake(t, server.URL+"/cover.png")
+	path := filepath.Join(t.TempDir(), "cover.jpg")
+
+	args := []string{"-provider", "fake", "fetch", "-o", path, "-size", "8", "-format", "jpeg", "album", "halcyon days", "ellie goulding"}
+	if code := run(args, ioutil.Discard, ioutil.Discard); code != 0 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil || format != "jpeg" || img.Bounds().Dx() != 8 || img.Bounds().Dy() != 8 {
+		t.Errorf("unexpected artwork %s %v", format, err)
+	}
+
+	stdout := bytes.Buffer{}
+	args = []string{"-provider", "fake", "fetch", "-o", "-", "track", "figure 8", "ellie goulding"}
+	if code := run(args, &stdout, ioutil.Discard); code != 0 || !bytes.Equal(stdout.Bytes(), buf.Bytes()) {
+		t.Errorf("expected the artwork on the standard output, got %d", code)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "Ellie Goulding", "Halcyon"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "Ellie Goulding", "Halcyon", "01.mp3"), []byte{0xff, 0xfb, 0x90, 0x64}, 0644)
+
+	buf := bytes.Buffer{}
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 20)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	useFake(t, server.URL+"/cover.png")
+
+	stdout := bytes.Buffer{}
+	args := []string{"-provider", "fake", "scan", "-workers", "2", "-size", "10", "-format", "jpeg", root}
+	if code := run(args, &stdout, ioutil.Discard); code != 0 || !strings.Contains(stdout.String(), "1 written") {
+		t.Fatalf("unexpected output %d %q", code, stdout.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "Ellie Goulding", "Halcyon", "folder.jpg")); err != nil {
+		t.Errorf("expected the artwork to be written, got %v", err)
+	}
+}
@@ -4,6 +4,10 @@
 //
 // Note: This is a lazy package to load all the sub-service packages concurrently.
 //
+// Each of the returned helpers implements the Provider interface, which gives
+// access to the artworks of any service through the same methods and Result.
+// An Aggregator sends the same lookup to several providers concurrently.
+//
 // Concerned packages:
 //
 // "github.com/piraveen/go-coverart/itunesart"
@@ -12,19 +16,34 @@
 package coverart
 
 import (
+	"context"
+
 	"github.com/piraveen/go-coverart/itunesart"
 	"github.com/piraveen/go-coverart/lastfmart"
 	"github.com/piraveen/go-coverart/spotifyart"
 )
 
-// The ItunesArt represents the specific helper methods of the itunesart package
+// The ItunesArt represents the specific helper methods of the itunesart package.
+// As a Provider, it uses the Context methods, or the plain ones when they are
+// not set, so a lookup is overridden by setting its Context method or by
+// setting its plain method and clearing the Context one.
 type ItunesArt struct {
-	Result     itunesart.Result
-	TrackCover func(track string, artist string) (itunesart.Result, error)
-	AlbumCover func(album string, artist string) (itunesart.Result, error)
+	Result      itunesart.Result
+	TrackCover  func(track string, artist string) (itunesart.Result, error)
+	AlbumCover  func(album string, artist string) (itunesart.Result, error)
+	ArtistCover func(artist string) (itunesart.Result, error)
+
+	TrackCoverContext  func(ctx context.Context, track string, artist string) (itunesart.Result, error)
+	AlbumCoverContext  func(ctx context.Context, album string, artist string) (itunesart.Result, error)
+	ArtistCoverContext func(ctx context.Context, artist string) (itunesart.Result, error)
+
+	TrackCandidatesContext func(ctx context.Context, track string, artist string, limit int) ([]itunesart.Candidate, error)
+	AlbumCandidatesContext func(ctx context.Context, album string, artist string, limit int) ([]itunesart.Candidate, error)
+	VerifyContext          func(ctx context.Context, res itunesart.Result) itunesart.Result
 }
 
-// The LastFmArt represents the specific helper methods of the lastfmart package
+// The LastFmArt represents the specific helper methods of the lastfmart package.
+// It uses the Context methods as a Provider, like ItunesArt.
 type LastFmArt struct {
 	Result      lastfmart.Result
 	CheckAPIKey func() error
@@ -33,9 +52,19 @@ type LastFmArt struct {
 	TrackCover  func(track string, artist string) (lastfmart.Result, error)
 	AlbumCover  func(album string, artist string) (lastfmart.Result, error)
 	ArtistCover func(artist string) (lastfmart.Result, error)
+
+	CheckAPIKeyContext func(ctx context.Context) error
+	TrackCoverContext  func(ctx context.Context, track string, artist string) (lastfmart.Result, error)
+	AlbumCoverContext  func(ctx context.Context, album string, artist string) (lastfmart.Result, error)
+	ArtistCoverContext func(ctx context.Context, artist string) (lastfmart.Result, error)
+
+	TrackCandidatesContext  func(ctx context.Context, track string, artist string, limit int) ([]lastfmart.Candidate, error)
+	AlbumCandidatesContext  func(ctx context.Context, album string, artist string, limit int) ([]lastfmart.Candidate, error)
+	ArtistCandidatesContext func(ctx context.Context, artist string, limit int) ([]lastfmart.Candidate, error)
 }
 
-// The SpotifyArt represents the specific helper methods of the spotifyart package
+// The SpotifyArt represents the specific helper methods of the spotifyart package.
+// It uses the Context methods as a Provider, like ItunesArt.
 type SpotifyArt struct {
 	Result           spotifyart.Result
 	CheckCredentials func() bool
@@ -44,6 +73,15 @@ type SpotifyArt struct {
 	TrackCover       func(track string, artists ...string) (spotifyart.Result, error)
 	AlbumCover       func(album string, artists ...string) (spotifyart.Result, error)
 	ArtistCover      func(artist string, genres ...string) (spotifyart.Result, error)
+
+	GetAccessTokenContext func(ctx context.Context) error
+	TrackCoverContext     func(ctx context.Context, track string, artists ...string) (spotifyart.Result, error)
+	AlbumCoverContext     func(ctx context.Context, album string, artists ...string) (spotifyart.Result, error)
+	ArtistCoverContext    func(ctx context.Context, artist string, genres ...string) (spotifyart.Result, error)
+
+	TrackCandidatesContext  func(ctx context.Context, track string, limit int, artists ...string) ([]spotifyart.Candidate, error)
+	AlbumCandidatesContext  func(ctx context.Context, album string, limit int, artists ...string) ([]spotifyart.Candidate, error)
+	ArtistCandidatesContext func(ctx context.Context, artist string, limit int, genres ...string) ([]spotifyart.Candidate, error)
 }
 
 // LastFm configures and returns all the exported methods of the package lastfmart
@@ -55,34 +93,129 @@ func LastFm(apiKey string) (LastFmArt, error) {
 	}
 
 	return LastFmArt{
-		lastfmart.Result{},
-		lastfmart.CheckAPIKey,
-		lastfmart.AutoCorrect,
-		lastfmart.SetAPIKey,
-		lastfmart.TrackCover,
-		lastfmart.AlbumCover,
-		lastfmart.ArtistCover,
+		Result:             lastfmart.Result{},
+		CheckAPIKey:        lastfmart.CheckAPIKey,
+		AutoCorrect:        lastfmart.AutoCorrect,
+		SetAPIKey:          lastfmart.SetAPIKey,
+		TrackCover:         lastfmart.TrackCover,
+		AlbumCover:         lastfmart.AlbumCover,
+		ArtistCover:        lastfmart.ArtistCover,
+		CheckAPIKeyContext: lastfmart.CheckAPIKeyContext,
+		TrackCoverContext:  lastfmart.TrackCoverContext,
+		AlbumCoverContext:  lastfmart.AlbumCoverContext,
+		ArtistCoverContext: lastfmart.ArtistCoverContext,
+
+		TrackCandidatesContext:  lastfmart.TrackCandidatesContext,
+		AlbumCandidatesContext:  lastfmart.AlbumCandidatesContext,
+		ArtistCandidatesContext: lastfmart.ArtistCandidatesContext,
+	}, nil
+}
+
+// NewLastFm returns all the exported methods of the given lastfmart Client, so
+// that several independently configured clients can be used at the same time
+func NewLastFm(c *lastfmart.Client) (LastFmArt, error) {
+	return NewLastFmContext(context.Background(), c)
+}
+
+// NewLastFmContext is like NewLastFm, but the check of the API Key is bound to
+// ctx so it can be cancelled or given a deadline
+func NewLastFmContext(ctx context.Context, c *lastfmart.Client) (LastFmArt, error) {
+	if err := c.CheckAPIKeyContext(ctx); err != nil {
+		return LastFmArt{}, err
+	}
+
+	return LastFmArt{
+		Result:             lastfmart.Result{},
+		CheckAPIKey:        c.CheckAPIKey,
+		AutoCorrect:        c.AutoCorrect,
+		SetAPIKey:          c.SetAPIKey,
+		TrackCover:         c.TrackCover,
+		AlbumCover:         c.AlbumCover,
+		ArtistCover:        c.ArtistCover,
+		CheckAPIKeyContext: c.CheckAPIKeyContext,
+		TrackCoverContext:  c.TrackCoverContext,
+		AlbumCoverContext:  c.AlbumCoverContext,
+		ArtistCoverContext: c.ArtistCoverContext,
+
+		TrackCandidatesContext:  c.TrackCandidatesContext,
+		AlbumCandidatesContext:  c.AlbumCandidatesContext,
+		ArtistCandidatesContext: c.ArtistCandidatesContext,
 	}, nil
 }
 
 // Itunes configures and returns all the exported methods of the package itunesart
 func Itunes() ItunesArt {
 	return ItunesArt{
-		itunesart.Result{},
-		itunesart.TrackCover,
-		itunesart.AlbumCover,
+		Result:             itunesart.Result{},
+		TrackCover:         itunesart.TrackCover,
+		AlbumCover:         itunesart.AlbumCover,
+		ArtistCover:        itunesart.ArtistCover,
+		TrackCoverContext:  itunesart.TrackCoverContext,
+		AlbumCoverContext:  itunesart.AlbumCoverContext,
+		ArtistCoverContext: itunesart.ArtistCoverContext,
+
+		TrackCandidatesContext: itunesart.TrackCandidatesContext,
+		AlbumCandidatesContext: itunesart.AlbumCandidatesContext,
+		VerifyContext:          itunesart.VerifyContext,
+	}
+}
+
+// NewItunes returns all the exported methods of the given itunesart Client
+func NewItunes(c *itunesart.Client) ItunesArt {
+	return ItunesArt{
+		Result:             itunesart.Result{},
+		TrackCover:         c.TrackCover,
+		AlbumCover:         c.AlbumCover,
+		ArtistCover:        c.ArtistCover,
+		TrackCoverContext:  c.TrackCoverContext,
+		AlbumCoverContext:  c.AlbumCoverContext,
+		ArtistCoverContext: c.ArtistCoverContext,
+
+		TrackCandidatesContext: c.TrackCandidatesContext,
+		AlbumCandidatesContext: c.AlbumCandidatesContext,
+		VerifyContext:          c.VerifyContext,
 	}
 }
 
 // Spotify configures and returns all the exported methods of the package spotifyart
 func Spotify() SpotifyArt {
 	return SpotifyArt{
-		spotifyart.Result{},
-		spotifyart.CheckCredentials,
-		spotifyart.GetAccessToken,
-		spotifyart.Configure,
-		spotifyart.TrackCover,
-		spotifyart.AlbumCover,
-		spotifyart.ArtistCover,
+		Result:                spotifyart.Result{},
+		CheckCredentials:      spotifyart.CheckCredentials,
+		GetAccessToken:        spotifyart.GetAccessToken,
+		Configure:             spotifyart.Configure,
+		TrackCover:            spotifyart.TrackCover,
+		AlbumCover:            spotifyart.AlbumCover,
+		ArtistCover:           spotifyart.ArtistCover,
+		GetAccessTokenContext: spotifyart.GetAccessTokenContext,
+		TrackCoverContext:     spotifyart.TrackCoverContext,
+		AlbumCoverContext:     spotifyart.AlbumCoverContext,
+		ArtistCoverContext:    spotifyart.ArtistCoverContext,
+
+		TrackCandidatesContext:  spotifyart.TrackCandidatesContext,
+		AlbumCandidatesContext:  spotifyart.AlbumCandidatesContext,
+		ArtistCandidatesContext: spotifyart.ArtistCandidatesContext,
+	}
+}
+
+// NewSpotify returns all the exported methods of the given spotifyart Client,
+// so that several independently configured clients can be used at the same time
+func NewSpotify(c *spotifyart.Client) SpotifyArt {
+	return SpotifyArt{
+		Result:                spotifyart.Result{},
+		CheckCredentials:      c.CheckCredentials,
+		GetAccessToken:        c.GetAccessToken,
+		Configure:             c.Configure,
+		TrackCover:            c.TrackCover,
+		AlbumCover:            c.AlbumCover,
+		ArtistCover:           c.ArtistCover,
+		GetAccessTokenContext: c.GetAccessTokenContext,
+		TrackCoverContext:     c.TrackCoverContext,
+		AlbumCoverContext:     c.AlbumCoverContext,
+		ArtistCoverContext:    c.ArtistCoverContext,
+
+		TrackCandidatesContext:  c.TrackCandidatesContext,
+		AlbumCandidatesContext:  c.AlbumCandidatesContext,
+		ArtistCandidatesContext: c.ArtistCandidatesContext,
 	}
 }
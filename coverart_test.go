@@ -1,12 +1,73 @@
 package coverart_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/piraveen/go-coverart"
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/itunesart"
+	"github.com/piraveen/go-coverart/lastfmart"
+	"github.com/piraveen/go-coverart/spotifyart"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+// fakeProvider returns the same result or error for every lookup
+type fakeProvider struct {
+	name  string
+	res   coverart.Result
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) lookup(ctx context.Context) (coverart.Result, error) {
+	p.calls++
+
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return coverart.Result{}, ctx.Err()
+	}
+
+	if p.err != nil {
+		return coverart.Result{}, p.err
+	}
+
+	res := p.res
+	res.Provider = p.name
+	return res, nil
+}
+
+func (p *fakeProvider) Album(ctx context.Context, album string, artist string) (coverart.Result, error) {
+	return p.lookup(ctx)
+}
+
+func (p *fakeProvider) Track(ctx context.Context, track string, artist string) (coverart.Result, error) {
+	return p.lookup(ctx)
+}
+
+func (p *fakeProvider) Artist(ctx context.Context, artist string) (coverart.Result, error) {
+	return p.lookup(ctx)
+}
+
 func TestItunes(t *testing.T) {
 	itunes := coverart.Itunes()
 
@@ -58,6 +119,822 @@ func TestSpotify(t *testing.T) {
 	}
 }
 
+func TestProvider(t *testing.T) {
+	itunes := coverart.Itunes()
+	itunes.AlbumCoverContext = func(ctx context.Context, album string, artist string) (itunesart.Result, error) {
+		return itunesart.Result{Tiny: "30", Medium: "100", Default: "100"}, nil
+	}
+
+	lastfm := coverart.LastFmArt{
+		AlbumCoverContext: func(ctx context.Context, album string, artist string) (lastfmart.Result, error) {
+			return lastfmart.Result{Small: "s", ExtraLarge: "xl", Default: "xl"}, nil
+		},
+	}
+
+	width := 640
+	sized := coverart.SpotifyArt{
+		AlbumCoverContext: func(ctx context.Context, album string, artists ...string) (spotifyart.Result, error) {
+			return spotifyart.Result{Large: "640", Default: "640", Images: []artwork.Source{{URL: "640", Width: width, Height: width}}}, nil
+		},
+	}
+
+	tests := []struct {
+		provider coverart.Provider
+		expected coverart.Result
+		largest  artwork.Source
+	}{
+		{itunes, coverart.Result{Provider: "itunes", Small: "30", Medium: "100", Default: "100"}, artwork.Source{URL: "100", Width: 100, Height: 100}},
+		{lastfm, coverart.Result{Provider: "lastfm", Small: "s", Large: "xl", Default: "xl"}, artwork.Source{URL: "xl", Width: 300, Height: 300}},
+		{sized, coverart.Result{Provider: "spotify", Large: "640", Default: "640"}, artwork.Source{URL: "640", Width: width, Height: width}},
+	}
+
+	for _, test := range tests {
+		res, err := test.provider.Album(context.Background(), "stay", "rihanna")
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.provider.Name(), err)
+		}
+
+		// The artwork carries the images of the service with their sizes
+		art := res.Artwork
+		if largest, ok := art.Largest(); art == nil || art.Provider != test.provider.Name() || !ok || largest != test.largest {
+			t.Errorf("%s: unexpected artwork %+v", test.provider.Name(), art)
+		}
+
+		if res.Artwork = nil; res != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.provider.Name(), test.expected, res)
+		}
+	}
+
+	itunes.ArtistCoverContext = func(ctx context.Context, artist string) (itunesart.Result, error) {
+		return itunesart.Result{}, artwork.NotFound("No match was found")
+	}
+
+	if _, err := itunes.Artist(context.Background(), "rihanna"); !errors.Is(err, coverart.ErrNotFound) {
+		t.Errorf("itunes: expected the error of the artist lookup, got %v", err)
+	}
+
+	// The plain methods are used when the Context ones are not set
+	spotify := coverart.SpotifyArt{
+		TrackCover: func(track string, artists ...string) (spotifyart.Result, error) {
+			return spotifyart.Result{Large: "640", Default: "640"}, nil
+		},
+	}
+
+	if res, err := spotify.Track(context.Background(), "stay", "rihanna"); err != nil || res.Default != "640" {
+		t.Errorf("spotify: unexpected result %+v %v", res, err)
+	}
+
+	// The helpers without the method fail instead of panicking
+	if _, err := spotify.Album(context.Background(), "unapologetic", "rihanna"); err == nil {
+		t.Errorf("spotify: expected an error without album lookup")
+	}
+
+	if _, err := coverart.NewMatcher(spotify).Track(context.Background(), "stay", "rihanna"); err != nil {
+		t.Errorf("spotify: expected the lookup to be used without candidates, got %v", err)
+	}
+}
+
+func TestResultURL(t *testing.T) {
+	if url := (coverart.Result{Small: "s", Medium: "m", Default: "m"}).URL(); url != "m" {
+		t.Errorf("expected the default artwork, got %q", url)
+	}
+
+	if url := (coverart.Result{Small: "s", Large: "l", Default: "s"}).URL(); url != "l" {
+		t.Errorf("expected the large artwork, got %q", url)
+	}
+
+	if _, err := coverart.Fetch(context.Background(), coverart.Result{}); !errors.Is(err, coverart.ErrNotFound) {
+		t.Errorf("expected a not found error without artwork, got %v", err)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	agg := coverart.NewAggregator(
+		&fakeProvider{name: "failing", err: artwork.NotFound("No match was found")},
+		&fakeProvider{name: "medium", res: coverart.Result{Medium: "m", Default: "m"}},
+		&fakeProvider{name: "slow", res: coverart.Result{Large: "l"}, delay: time.Second},
+		&fakeProvider{name: "large", res: coverart.Result{Large: "l", Default: "l"}},
+	)
+	agg.Timeout = 50 * time.Millisecond
+
+	hits := agg.Album(context.Background(), "halcyon days", "ellie goulding")
+	if len(hits) != 4 {
+		t.Fatalf("expected 4 hits, got %d", len(hits))
+	}
+
+	expected := []string{"large", "medium", "failing", "slow"}
+	for i, name := range expected {
+		if hits[i].Provider != name {
+			t.Errorf("hit %d: expected %s, got %s", i, name, hits[i].Provider)
+		}
+	}
+
+	if hits[3].Err != context.DeadlineExceeded {
+		t.Errorf("expected the slow provider to time out, got %v", hits[3].Err)
+	}
+}
+
+// expiredContext is done once its providers have answered, so that the
+// deadline and their results are ready at the same time
+type expiredContext struct {
+	context.Context
+	answered *sync.WaitGroup
+}
+
+func (ctx expiredContext) Done() <-chan struct{} {
+	ctx.answered.Wait()
+	// Leaves the time to send the results once the lookups returned
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (ctx expiredContext) Err() error {
+	return context.DeadlineExceeded
+}
+
+// answeringProvider answers immediately, whatever the context
+type answeringProvider struct {
+	fakeProvider
+	answered *sync.WaitGroup
+}
+
+func (p *answeringProvider) Album(ctx context.Context, album string, artist string) (coverart.Result, error) {
+	defer p.answered.Done()
+	return coverart.Result{Provider: p.name, Default: p.name}, nil
+}
+
+func TestAggregatorDeadline(t *testing.T) {
+	answered := &sync.WaitGroup{}
+	answered.Add(3)
+
+	agg := coverart.NewAggregator(
+		&answeringProvider{fakeProvider{name: "first"}, answered},
+		&answeringProvider{fakeProvider{name: "second"}, answered},
+		&answeringProvider{fakeProvider{name: "third"}, answered},
+	)
+
+	// The results which arrived before the deadline are not timed out
+	hits := agg.Album(expiredContext{context.Background(), answered}, "halcyon days", "ellie goulding")
+	for _, h := range hits {
+		if h.Err != nil || h.Result.Default != h.Provider {
+			t.Errorf("unexpected hit %+v", h)
+		}
+	}
+}
+
+func ExampleAggregator() {
+	agg := coverart.NewAggregator(coverart.Itunes(), coverart.Spotify())
+	agg.Timeout = 5 * time.Second
+
+	for _, hit := range agg.Album(context.Background(), "halcyon days", "ellie goulding") {
+		if hit.Err == nil {
+			fmt.Printf("%s AlbumCover %v\n", hit.Provider, hit.Result.Default)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	notFound := &fakeProvider{name: "notfound", err: artwork.NotFound("No match was found")}
+	limited := &fakeProvider{name: "limited", err: &coverart.RateLimitError{Message: "Slow down"}}
+	failing := &fakeProvider{name: "failing", err: artwork.Unauthorized("Invalid API Key")}
+	found := &fakeProvider{name: "found", res: coverart.Result{Default: "d"}}
+
+	chain := coverart.NewChain(notFound, limited, failing, found)
+	if _, err := chain.Album(context.Background(), "stay", "rihanna"); err != failing.err {
+		t.Errorf("expected the chain to stop at the failing provider, got %v", err)
+	}
+
+	chain.Fallback["failing"] = coverart.AlwaysFallback
+	res, err := chain.Album(context.Background(), "stay", "rihanna")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if res.Provider != "found" || found.calls != 1 {
+		t.Errorf("expected the result of the last provider, got %+v", res)
+	}
+
+	if chain.Name() != "notfound,limited,failing,found" {
+		t.Errorf("unexpected chain name %s", chain.Name())
+	}
+}
+
+func TestHelperMethods(t *testing.T) {
+	itunes := coverart.ItunesArt{
+		AlbumCover: func(album string, artist string) (itunesart.Result, error) {
+			return itunesart.Result{Default: album + "/" + artist}, nil
+		},
+		ArtistCoverContext: func(ctx context.Context, artist string) (itunesart.Result, error) {
+			return itunesart.Result{Default: artist}, nil
+		},
+	}
+
+	lastfm := coverart.LastFmArt{
+		TrackCoverContext: func(ctx context.Context, track string, artist string) (lastfmart.Result, error) {
+			return lastfmart.Result{Default: track + "/" + artist}, nil
+		},
+		ArtistCover: func(artist string) (lastfmart.Result, error) {
+			return lastfmart.Result{Default: artist}, nil
+		},
+	}
+
+	spotify := coverart.SpotifyArt{
+		AlbumCoverContext: func(ctx context.Context, album string, artists ...string) (spotifyart.Result, error) {
+			return spotifyart.Result{Default: album + "/" + strings.Join(artists, ",")}, nil
+		},
+		TrackCover: func(track string, artists ...string) (spotifyart.Result, error) {
+			return spotifyart.Result{Default: track + "/" + strings.Join(artists, ",")}, nil
+		},
+		ArtistCover: func(artist string, genres ...string) (spotifyart.Result, error) {
+			return spotifyart.Result{Default: artist + "/" + strings.Join(genres, ",")}, nil
+		},
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		lookup   func() (coverart.Result, error)
+		expected string
+	}{
+		{func() (coverart.Result, error) { return itunes.Album(ctx, "stay", "rihanna") }, "stay/rihanna"},
+		{func() (coverart.Result, error) { return itunes.Artist(ctx, "rihanna") }, "rihanna"},
+		{func() (coverart.Result, error) { return lastfm.Track(ctx, "stay", "rihanna") }, "stay/rihanna"},
+		{func() (coverart.Result, error) { return lastfm.Artist(ctx, "rihanna") }, "rihanna"},
+		{func() (coverart.Result, error) { return spotify.Album(ctx, "stay", "rihanna") }, "stay/rihanna"},
+		{func() (coverart.Result, error) { return spotify.Track(ctx, "stay", "rihanna") }, "stay/rihanna"},
+		{func() (coverart.Result, error) { return spotify.Artist(ctx, "rihanna") }, "rihanna/"},
+	}
+
+	for i, test := range tests {
+		if res, err := test.lookup(); err != nil || res.Default != test.expected {
+			t.Errorf("%d: expected %s, got %+v %v", i, test.expected, res, err)
+		}
+	}
+
+	// The lookups without a method are not found, a chain moves on
+	if _, err := itunes.Track(ctx, "stay", "rihanna"); !errors.Is(err, coverart.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	if res, err := coverart.NewChain(coverart.LastFmArt{}, itunes).Album(ctx, "stay", "rihanna"); err != nil || res.Provider != "itunes" {
+		t.Errorf("expected the zero helper to be skipped, got %+v %v", res, err)
+	}
+}
+
+func TestSearcher(t *testing.T) {
+	itunes := coverart.Itunes()
+	itunes.AlbumCandidatesContext = func(ctx context.Context, album string, artist string, limit int) ([]itunesart.Candidate, error) {
+		return []itunesart.Candidate{
+			{ID: 1, Name: "Halcyon Days", Artist: "Ellie Goulding", Album: "Halcyon Days", ReleaseDate: "2013-08-23", Result: itunesart.Result{Medium: "100", Default: "100"}},
+		}, nil
+	}
+
+	spotify := coverart.Spotify()
+	spotify.AlbumCandidatesContext = func(ctx context.Context, album string, limit int, artists ...string) ([]spotifyart.Candidate, error) {
+		return nil, artwork.NotFound("No image was found")
+	}
+
+	lastfm := coverart.LastFmArt{
+		AlbumCandidatesContext: func(ctx context.Context, album string, artist string, limit int) ([]lastfmart.Candidate, error) {
+			return []lastfmart.Candidate{
+				{Name: "Halcyon", Artist: "Ellie Goulding", MBID: "mbid", Result: lastfmart.Result{Large: "l", Default: "l"}},
+			}, nil
+		},
+	}
+
+	chain := coverart.NewChain(&fakeProvider{name: "fake"}, spotify, itunes, lastfm)
+	candidates, err := chain.AlbumCandidates(context.Background(), "halcyon days", "ellie goulding", 5)
+	if err != nil || len(candidates) != 2 {
+		t.Fatalf("unexpected candidates %+v %v", candidates, err)
+	}
+
+	expected := coverart.Candidate{
+		Provider:    "itunes",
+		ID:          "1",
+		Name:        "Halcyon Days",
+		Artist:      "Ellie Goulding",
+		Album:       "Halcyon Days",
+		ReleaseDate: "2013-08-23",
+		Result:      coverart.Result{Provider: "itunes", Medium: "100", Default: "100"},
+	}
+
+	if art := candidates[0].Result.Artwork; art == nil || art.ID != "1" || art.Name != "Halcyon Days" {
+		t.Errorf("unexpected artwork %+v", art)
+	}
+
+	if candidates[0].Result.Artwork = nil; candidates[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, candidates[0])
+	}
+
+	if c := candidates[1]; c.Provider != "lastfm" || c.ID != "mbid" || c.Album != "Halcyon" || c.Result.Large != "l" {
+		t.Errorf("unexpected candidate %+v", c)
+	}
+
+	if _, err := coverart.NewChain(&fakeProvider{name: "fake"}).TrackCandidates(context.Background(), "figure 8", "", 5); err == nil {
+		t.Errorf("expected an error without searchers")
+	}
+
+	// The providers which can not search are looked up when the others find
+	// nothing, the Matcher of a chain finds what the chain finds
+	itunes.ArtistCoverContext = func(ctx context.Context, artist string) (itunesart.Result, error) {
+		return itunesart.Result{Medium: "100", Default: "100"}, nil
+	}
+
+	lastfm.ArtistCandidatesContext = func(ctx context.Context, artist string, limit int) ([]lastfmart.Candidate, error) {
+		return nil, artwork.NotFound("No artist was found")
+	}
+
+	chain = coverart.NewChain(itunes, lastfm)
+	if _, err := chain.ArtistCandidates(context.Background(), "ellie goulding", 5); !errors.Is(err, coverart.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	for _, p := range []coverart.Provider{chain, coverart.NewMatcher(chain)} {
+		if res, err := p.Artist(context.Background(), "ellie goulding"); err != nil || res.Provider != "itunes" || res.Default != "100" {
+			t.Errorf("unexpected artist result %+v %v", res, err)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	itunes := coverart.Itunes()
+	itunes.TrackCandidatesContext = func(ctx context.Context, track string, artist string, limit int) ([]itunesart.Candidate, error) {
+		if limit != 10 {
+			t.Errorf("unexpected limit %d", limit)
+		}
+
+		return []itunesart.Candidate{
+			{ID: 1, Name: "Stay (Karaoke Version)", Artist: "Karaoke Hits", Result: itunesart.Result{Default: "karaoke"}},
+			{ID: 2, Name: "Stay", Artist: "Zedd & Alessia Cara", Result: itunesart.Result{Default: "zedd"}},
+			{ID: 3, Name: "Stay", Artist: "Rihanna", Result: itunesart.Result{Default: "rihanna"}},
+		}, nil
+	}
+
+	matcher := coverart.NewMatcher(itunes)
+	res, err := matcher.Track(context.Background(), "stay", "rihanna")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if res.Default != "rihanna" || res.Confidence != 1 || res.Provider != "itunes" {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	candidates, err := matcher.TrackCandidates(context.Background(), "stay", "rihanna", 10)
+	if err != nil || len(candidates) != 2 || candidates[1].ID != "2" || candidates[1].Score >= candidates[0].Score {
+		t.Errorf("unexpected candidates %+v %v", candidates, err)
+	}
+
+	// Only the artworks of the best match are verified, in a chain as well
+	verified := []string{}
+	itunes.VerifyContext = func(ctx context.Context, res itunesart.Result) itunesart.Result {
+		verified = append(verified, res.Default)
+		res.Large = res.Default + "-large"
+		return res
+	}
+
+	for _, p := range []coverart.Provider{itunes, coverart.NewChain(itunes)} {
+		verified = verified[:0]
+		res, err := coverart.NewMatcher(p).Track(context.Background(), "stay", "rihanna")
+		if err != nil || res.Large != "rihanna-large" || res.Confidence != 1 || len(verified) != 1 {
+			t.Errorf("%s: unexpected result %+v %v, verified %v", p.Name(), res, err, verified)
+		}
+	}
+
+	matcher.Threshold = 0.9
+	if _, err := matcher.Track(context.Background(), "umbrella", "rihanna"); !errors.Is(err, coverart.ErrNotFound) {
+		t.Errorf("expected a not found error below the threshold, got %v", err)
+	}
+
+	// Itunes can not list artists, the artist lookup is used instead, alone
+	// or in a chain
+	itunes.ArtistCoverContext = func(ctx context.Context, artist string) (itunesart.Result, error) {
+		return itunesart.Result{Medium: "100", Default: "100"}, nil
+	}
+
+	for _, p := range []coverart.Provider{itunes, coverart.NewChain(itunes)} {
+		if res, err := coverart.NewMatcher(p).Artist(context.Background(), "rihanna"); err != nil || res.Default != "100" || res.Provider != "itunes" {
+			t.Errorf("%s: unexpected artist result %+v %v", p.Name(), res, err)
+		}
+	}
+
+	// The results of the providers which can not list candidates are not
+	// verified
+	fake := &fakeProvider{name: "fake", res: coverart.Result{Default: "d"}}
+	if res, err := coverart.NewMatcher(fake).Album(context.Background(), "stay", "rihanna"); err != nil || res.Default != "d" || res.Confidence != 0 {
+		t.Errorf("unexpected result %+v %v", res, err)
+	}
+
+	// In a chain mixing both, the candidates of the searchers are preferred,
+	// the others are only looked up when the searchers find nothing
+	lastfm := coverart.LastFmArt{
+		AlbumCandidatesContext: func(ctx context.Context, album string, artist string, limit int) ([]lastfmart.Candidate, error) {
+			return []lastfmart.Candidate{{Name: "Stay", Artist: "Rihanna", Result: lastfmart.Result{Default: "lastfm"}}}, nil
+		},
+		TrackCandidatesContext: func(ctx context.Context, track string, artist string, limit int) ([]lastfmart.Candidate, error) {
+			return nil, artwork.NotFound("No track was found")
+		},
+		TrackCoverContext: func(ctx context.Context, track string, artist string) (lastfmart.Result, error) {
+			t.Errorf("expected the searchers not to be looked up again")
+			return lastfmart.Result{}, artwork.NotFound("No track was found")
+		},
+	}
+
+	fake.calls = 0
+	matcher = coverart.NewMatcher(coverart.NewChain(lastfm, fake))
+	if res, err := matcher.Album(context.Background(), "stay", "rihanna"); err != nil || res.Default != "lastfm" || res.Confidence != 1 || fake.calls != 0 {
+		t.Errorf("unexpected album result %+v %v", res, err)
+	}
+
+	if res, err := matcher.Track(context.Background(), "stay", "rihanna"); err != nil || res.Default != "d" || res.Provider != "fake" || fake.calls != 1 {
+		t.Errorf("unexpected track result %+v %v", res, err)
+	}
+}
+
+func TestScore(t *testing.T) {
+	c := coverart.Candidate{Name: "Halcyon Days", Artist: "Calvin Harris, Ellie Goulding"}
+	if s := coverart.Score("halcyon days", "ellie goulding", c); s != 1 {
+		t.Errorf("expected a perfect score for one of the artists, got %.2f", s)
+	}
+
+	if s := coverart.Score("halcyon days", "", c); s != 1 {
+		t.Errorf("expected the artist to be ignored, got %.2f", s)
+	}
+
+	if s := coverart.Score("halcyon days", "rihanna", c); s > 0.7 {
+		t.Errorf("expected a low score for another artist, got %.2f", s)
+	}
+}
+
+func ExampleMatcher() {
+	// Reject the matches which do not look like the query
+	matcher := coverart.NewMatcher(coverart.Itunes())
+	matcher.Threshold = 0.8
+
+	results, err := matcher.Track(context.Background(), "stay", "rihanna")
+	if err == nil {
+		fmt.Printf("TrackCover %v with a confidence of %.2f\n", results.Default, results.Confidence)
+	}
+}
+
+func ExampleChain() {
+	chain := coverart.NewChain(coverart.Spotify(), coverart.Itunes())
+
+	results, err := chain.Album(context.Background(), "halcyon days", "ellie goulding")
+	if err == nil {
+		fmt.Printf("%s AlbumCover %v\n", results.Provider, results.Default)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	a := coverart.CacheKey("itunes", coverart.AlbumKind, "Halcyon  Days ", "Ellie Goulding")
+	b := coverart.CacheKey("itunes", coverart.AlbumKind, "halcyon days", "ellie goulding")
+	c := coverart.CacheKey("spotify", coverart.AlbumKind, "halcyon days", "ellie goulding")
+
+	if a != b {
+		t.Errorf("expected %q and %q to be equal", a, b)
+	}
+
+	if a == c {
+		t.Errorf("expected the providers to have different keys")
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	cache := coverart.NewMemoryCache(2, time.Hour)
+	cache.Set("a", coverart.Result{Default: "a"})
+	cache.Set("b", coverart.Result{Default: "b"})
+	cache.Get("a")
+	cache.Set("c", coverart.Result{Default: "c"})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected the least recently used result to be evicted")
+	}
+
+	if res, ok := cache.Get("a"); !ok || res.Default != "a" {
+		t.Errorf("expected the result of a, got %+v", res)
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 results, got %d", cache.Len())
+	}
+
+	expiring := coverart.NewMemoryCache(2, time.Millisecond)
+	expiring.Set("a", coverart.Result{Default: "a"})
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := expiring.Get("a"); ok {
+		t.Errorf("expected the result to be expired")
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := coverart.NewFileCache(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	cache.Set("a", coverart.Result{Provider: "itunes", Default: "a"})
+
+	// A new cache on the same directory, like after a restart
+	cache, _ = coverart.NewFileCache(dir, time.Hour)
+	if res, ok := cache.Get("a"); !ok || res.Default != "a" || res.Provider != "itunes" {
+		t.Errorf("expected the result of a, got %+v", res)
+	}
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected no result for b")
+	}
+
+	cache.SetCandidates("c", []coverart.Candidate{{Provider: "itunes", Name: "Halcyon"}})
+	if candidates, ok := cache.GetCandidates("c"); !ok || len(candidates) != 1 || candidates[0].Name != "Halcyon" {
+		t.Errorf("expected the candidates of c, got %+v", candidates)
+	}
+}
+
+func TestCached(t *testing.T) {
+	fake := &fakeProvider{name: "fake", res: coverart.Result{Default: "d"}}
+	p := coverart.Cached(fake, coverart.NewMemoryCache(10, time.Hour))
+
+	for _, album := range []string{"Halcyon Days", "halcyon days"} {
+		res, err := p.Album(context.Background(), album, "ellie goulding")
+		if err != nil || res.Default != "d" {
+			t.Fatalf("unexpected result %+v, %v", res, err)
+		}
+	}
+
+	if fake.calls != 1 {
+		t.Errorf("expected a single lookup, got %d", fake.calls)
+	}
+
+	if _, ok := p.(coverart.Searcher); ok {
+		t.Errorf("expected the provider not to be a searcher")
+	}
+
+	// The searches and the verifications of the Matcher are cached as well
+	searches, verified := 0, 0
+	itunes := coverart.Itunes()
+	itunes.AlbumCandidatesContext = func(ctx context.Context, album string, artist string, limit int) ([]itunesart.Candidate, error) {
+		searches++
+		return []itunesart.Candidate{{ID: 1, Name: "Halcyon Days", Artist: "Ellie Goulding", Result: itunesart.Result{Large: "l", Default: "d"}}}, nil
+	}
+
+	itunes.VerifyContext = func(ctx context.Context, res itunesart.Result) itunesart.Result {
+		verified++
+		res.Default = res.Large
+		return res
+	}
+
+	matcher := coverart.NewMatcher(coverart.Cached(itunes, coverart.NewMemoryCache(10, time.Hour)))
+	for _, album := range []string{"Halcyon Days", "halcyon days"} {
+		res, err := matcher.Album(context.Background(), album, "ellie goulding")
+		if err != nil || res.Default != "l" || res.Confidence != 1 {
+			t.Fatalf("unexpected result %+v, %v", res, err)
+		}
+	}
+
+	if searches != 1 || verified != 1 {
+		t.Errorf("expected a single search and verification, got %d and %d", searches, verified)
+	}
+}
+
+// queryProvider records the lookups, the album lookups fail when missing is
+// true
+type queryProvider struct {
+	missing bool
+	queries []string
+}
+
+func (p *queryProvider) Name() string {
+	return "query"
+}
+
+func (p *queryProvider) Album(ctx context.Context, album string, artist string) (coverart.Result, error) {
+	p.queries = append(p.queries, "album "+album+" "+artist)
+	if p.missing {
+		return coverart.Result{}, artwork.NotFound("missing")
+	}
+
+	return coverart.Result{Default: "d"}, nil
+}
+
+func (p *queryProvider) Track(ctx context.Context, track string, artist string) (coverart.Result, error) {
+	p.queries = append(p.queries, "track "+track+" "+artist)
+	return coverart.Result{Default: "d"}, nil
+}
+
+func (p *queryProvider) Artist(ctx context.Context, artist string) (coverart.Result, error) {
+	p.queries = append(p.queries, "artist "+artist)
+	return coverart.Result{Default: "d"}, nil
+}
+
+func TestLookupTags(t *testing.T) {
+	tests := []struct {
+		tags     coverart.Tags
+		missing  bool
+		expected string
+	}{
+		{coverart.Tags{Album: "Halcyon", AlbumArtist: "Ellie Goulding", Artist: "Ellie", Title: "Figure 8"}, false, "album Halcyon Ellie Goulding"},
+		{coverart.Tags{Album: "Halcyon", Artist: "Ellie", Title: "Figure 8"}, true, "album Halcyon Ellie,track Figure 8 Ellie"},
+		{coverart.Tags{Title: "Figure 8", AlbumArtist: "Ellie Goulding"}, false, "track Figure 8 Ellie Goulding"},
+		{coverart.Tags{Artist: "Ellie Goulding"}, false, "artist Ellie Goulding"},
+	}
+
+	for _, test := range tests {
+		p := &queryProvider{missing: test.missing}
+		res, err := coverart.LookupTags(context.Background(), p, &test.tags)
+		if err != nil || res.Default != "d" || strings.Join(p.queries, ",") != test.expected {
+			t.Errorf("unexpected lookups %q for %+v, %v", p.queries, test.tags, err)
+		}
+	}
+
+	p := &queryProvider{missing: true}
+	tags := coverart.Tags{Album: "Halcyon"}
+	if _, err := coverart.LookupTags(context.Background(), p, &tags); !errors.Is(err, coverart.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	if _, err := coverart.LookupTags(context.Background(), p, &coverart.Tags{}); !errors.Is(err, coverart.ErrNotFound) {
+		t.Errorf("expected a not found error for empty tags, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	buf := bytes.Buffer{}
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10)))
+
+	var downloads int32
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	defer images.Close()
+
+	logs := bytes.Buffer{}
+	fake := &fakeProvider{name: "fake", res: coverart.Result{Large: images.URL + "/cover.png"}}
+	handler := coverart.NewHandler(fake)
+	handler.ErrorLog = log.New(&logs, "", 0)
+
+	server := httptest.NewServer(http.StripPrefix("/v1", handler))
+	defer server.Close()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+	}{
+		{"/v1/album?album=halcyon&artist=ellie+goulding", http.StatusOK, "application/json"},
+		{"/v1/track?track=figure+8&mode=redirect", http.StatusFound, ""},
+		{"/v1/artist?artist=ellie+goulding&mode=proxy", http.StatusOK, "image/png"},
+		{"/v1/artist?artist=ellie+goulding&mode=proxy&size=8&format=jpeg", http.StatusOK, "image/jpeg"},
+		{"/v1/album?artist=ellie+goulding", http.StatusBadRequest, "application/json"},
+		{"/v1/album?album=halcyon&mode=unknown", http.StatusBadRequest, "application/json"},
+		{"/v1/album?album=halcyon&mode=proxy&size=-1", http.StatusBadRequest, "application/json"},
+		{"/v1/unknown", http.StatusNotFound, "application/json"},
+	}
+
+	for _, test := range tests {
+		resp, err := client.Get(server.URL + test.path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.path, err)
+		}
+
+		resp.Body.Close()
+		if resp.StatusCode != test.status || (len(test.contentType) > 0 && resp.Header.Get("Content-Type") != test.contentType) {
+			t.Errorf("%s: unexpected response %d %s", test.path, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		if resp.StatusCode == http.StatusFound && resp.Header.Get("Location") != fake.res.Large {
+			t.Errorf("%s: unexpected redirect to %s", test.path, resp.Header.Get("Location"))
+		}
+
+		if resp.StatusCode < 300 && !strings.HasPrefix(resp.Header.Get("Cache-Control"), "public, max-age=") {
+			t.Errorf("%s: expected the response to be cacheable", test.path)
+		}
+	}
+
+	// The proxied artworks are revalidated with their ETag
+	resp, _ := http.Get(server.URL + "/v1/album?album=halcyon&mode=proxy")
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !bytes.Equal(data, buf.Bytes()) || len(resp.Header.Get("ETag")) == 0 {
+		t.Fatalf("unexpected proxied artwork")
+	}
+
+	// The artwork is neither downloaded to revalidate it nor for a HEAD
+	// request
+	atomic.StoreInt32(&downloads, 0)
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/album?album=halcyon&mode=proxy", nil)
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected the artwork not to be modified, got %v", err)
+	}
+
+	resized, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/album?album=halcyon&mode=proxy&size=8", nil)
+	resized.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	if resp, err := http.DefaultClient.Do(resized); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the resized artwork to have another ETag, got %v", err)
+	}
+
+	resp, err := http.Head(server.URL + "/v1/album?album=halcyon&mode=proxy")
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" || len(resp.Header.Get("ETag")) == 0 {
+		t.Errorf("unexpected HEAD response %+v %v", resp, err)
+	}
+
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("expected the resized artwork to be the only download, got %d", n)
+	}
+
+	fake.err = artwork.NotFound("missing")
+	if resp, err := http.Get(server.URL + "/v1/album?album=halcyon"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a not found status, got %v", err)
+	}
+
+	fake.err = &artwork.RateLimitError{RetryAfter: 3 * time.Second}
+	resp, err = http.Get(server.URL + "/v1/album?album=halcyon")
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "3" {
+		t.Errorf("expected a rate limit status, got %v", err)
+	}
+
+	// The rejected credentials of the provider are an internal error
+	fake.err = artwork.Unauthorized("Invalid API key")
+	resp, err = http.Get(server.URL + "/v1/album?album=halcyon")
+	if err != nil || resp.StatusCode != http.StatusInternalServerError || !strings.Contains(logs.String(), "Invalid API key") {
+		t.Errorf("expected an internal error to be logged, got %v %q", err, logs.String())
+	}
+
+	if resp, err := http.Post(server.URL+"/v1/album?album=halcyon", "text/plain", nil); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected a method not allowed status, got %v", err)
+	}
+}
+
+func ExampleHandler() {
+	chain := coverart.NewChain(coverart.Spotify(), coverart.Itunes())
+	cached := coverart.Cached(chain, coverart.NewMemoryCache(10000, 24*time.Hour))
+
+	handler := coverart.NewHandler(cached)
+	handler.Timeout = 10 * time.Second
+
+	http.Handle("/coverart/", http.StripPrefix("/coverart", handler))
+	http.ListenAndServe(":8080", nil)
+}
+
+func TestLookupFile(t *testing.T) {
+	dir := t.TempDir()
+	p := &queryProvider{}
+
+	if _, err := coverart.LookupFile(context.Background(), p, filepath.Join(dir, "track.wav")); !errors.Is(err, coverart.ErrUnsupportedFile) {
+		t.Errorf("expected an unsupported file error, got %v", err)
+	}
+
+	// An MP3 file without tag
+	path := filepath.Join(dir, "track.mp3")
+	ioutil.WriteFile(path, []byte{0xff, 0xfb, 0x90, 0x64}, 0644)
+
+	if _, err := coverart.LookupFile(context.Background(), p, path); !errors.Is(err, coverart.ErrNotFound) || len(p.queries) > 0 {
+		t.Errorf("expected a not found error without lookup, got %v", err)
+	}
+}
+
+func ExampleLookupFile() {
+	result, err := coverart.LookupFile(context.Background(), coverart.Itunes(), "track.mp3")
+	if err == nil {
+		fmt.Printf("AlbumCover %v\n", result.URL())
+	}
+}
+
+func ExampleCached() {
+	// Keep up to 1000 results for an hour
+	cache := coverart.NewMemoryCache(1000, time.Hour)
+	itunes := coverart.Cached(coverart.Itunes(), cache)
+
+	results, err := itunes.Album(context.Background(), "halcyon days", "ellie goulding")
+	if err == nil {
+		fmt.Printf("AlbumCover %v\n", results.Default)
+	}
+}
+
+func ExampleProvider() {
+	providers := []coverart.Provider{coverart.Itunes(), coverart.Spotify()}
+
+	for _, p := range providers {
+		results, err := p.Album(context.Background(), "halcyon days", "ellie goulding")
+		if err == nil {
+			fmt.Printf("%s AlbumCover %v\n", p.Name(), results.Default)
+		}
+	}
+}
+
 func ExampleItunes() {
 	itunes := coverart.Itunes()
 
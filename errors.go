@@ -0,0 +1,20 @@
+package coverart
+
+import "github.com/piraveen/go-coverart/artwork"
+
+// Error values returned by every provider, they can be checked with errors.Is.
+// See the artwork package for details.
+var (
+	ErrNotFound     = artwork.ErrNotFound
+	ErrUnauthorized = artwork.ErrUnauthorized
+	ErrRateLimited  = artwork.ErrRateLimited
+	ErrUpstream     = artwork.ErrUpstream
+)
+
+// The RateLimitError is returned when a provider rate limits the requests, it
+// holds the delay to wait before retrying
+type RateLimitError = artwork.RateLimitError
+
+// The UpstreamError is returned when a provider failed to answer a request, it
+// holds the HTTP status of the response
+type UpstreamError = artwork.UpstreamError
@@ -0,0 +1,23 @@
+package coverart
+
+import (
+	"context"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// URL returns the url of the largest artwork of the result
+func (r Result) URL() string {
+	return firstOf(r.Large, r.Default, r.Medium, r.Small)
+}
+
+// Fetch downloads the largest artwork of the result with the default
+// artwork.Fetcher and returns the validated image
+func Fetch(ctx context.Context, r Result) (*artwork.Image, error) {
+	url := r.URL()
+	if len(url) == 0 {
+		return nil, artwork.NotFound("No artwork was found")
+	}
+
+	return artwork.Fetch(ctx, url)
+}
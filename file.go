@@ -0,0 +1,133 @@
+package coverart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/flacart"
+	"github.com/piraveen/go-coverart/id3art"
+	"github.com/piraveen/go-coverart/mp4art"
+	"github.com/piraveen/go-coverart/oggart"
+)
+
+// ErrUnsupportedFile is returned for the audio files whose tags can not be
+// read
+var ErrUnsupportedFile = errors.New("Unsupported audio file")
+
+// The Tags represents the album, artists and title read from the tags of an
+// audio file
+type Tags = artwork.Tags
+
+// Tag readers of the supported audio files, by extension
+var tagReaders = map[string]func(path string) (*artwork.Tags, error){
+	".mp3":  id3art.ReadTags,
+	".flac": flacart.ReadTags,
+	".ogg":  oggart.ReadTags,
+	".oga":  oggart.ReadTags,
+	".opus": oggart.ReadTags,
+	".m4a":  mp4art.ReadTags,
+	".m4b":  mp4art.ReadTags,
+	".mp4":  mp4art.ReadTags,
+}
+
+// The coverFormat represents the helper methods of the audio files which can
+// hold an artwork
+type coverFormat struct {
+	hasCover func(path string) (bool, error)
+	embed    func(path string, img *artwork.Image, replace bool) error
+}
+
+// Helper methods of the audio files which can hold an artwork, by extension
+var coverFormats = map[string]coverFormat{
+	".mp3":  {id3art.HasCover, id3art.Embed},
+	".flac": {flacart.HasCover, flacart.Embed},
+	".m4a":  {mp4art.HasCover, mp4art.Embed},
+	".m4b":  {mp4art.HasCover, mp4art.Embed},
+	".mp4":  {mp4art.HasCover, mp4art.Embed},
+}
+
+// Used to get the helper methods of the audio file from its extension
+func coverFormatOf(path string) (coverFormat, error) {
+	f, ok := coverFormats[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return coverFormat{}, fmt.Errorf("%w: %s", ErrUnsupportedFile, filepath.Base(path))
+	}
+
+	return f, nil
+}
+
+// HasCover reports if the audio file has an embedded artwork. MP3, FLAC, MP4
+// and M4A files are supported, see the id3art, flacart and mp4art packages.
+func HasCover(path string) (bool, error) {
+	f, err := coverFormatOf(path)
+	if err != nil {
+		return false, err
+	}
+
+	return f.hasCover(path)
+}
+
+// Embed writes the artwork into the audio file, with the package matching its
+// extension. An existing artwork is only replaced when replace is true.
+func Embed(path string, img *artwork.Image, replace bool) error {
+	f, err := coverFormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	return f.embed(path, img, replace)
+}
+
+// ReadTags returns the tags of the audio file, ID3v2 tags for MP3 files, Vorbis
+// comments for FLAC, Ogg Vorbis and Opus files, and the iTunes metadata for
+// MP4 and M4A files. The format is chosen from the extension of the file.
+func ReadTags(path string) (*Tags, error) {
+	read, ok := tagReaders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFile, filepath.Base(path))
+	}
+
+	return read(path)
+}
+
+// LookupTags looks up the artwork matching the tags with the provider. The
+// album artwork is looked up first, by the album artist when the tags have
+// one. The track artwork is looked up when the album is not found, then the
+// artist artwork when the tags only have an artist.
+func LookupTags(ctx context.Context, p Provider, tags *Tags) (Result, error) {
+	artist := firstOf(tags.Artist, tags.AlbumArtist)
+	err := artwork.NotFound("The tags have no album, title or artist")
+
+	if len(tags.Album) > 0 {
+		var res Result
+		res, err = p.Album(ctx, tags.Album, firstOf(tags.AlbumArtist, tags.Artist))
+		if !errors.Is(err, ErrNotFound) {
+			return res, err
+		}
+	}
+
+	if len(tags.Title) > 0 {
+		return p.Track(ctx, tags.Title, artist)
+	}
+
+	if len(tags.Album) == 0 && len(artist) > 0 {
+		return p.Artist(ctx, artist)
+	}
+
+	return Result{}, err
+}
+
+// LookupFile reads the tags of the audio file and looks up the matching
+// artwork with the provider, see ReadTags and LookupTags
+func LookupFile(ctx context.Context, p Provider, path string) (Result, error) {
+	tags, err := ReadTags(path)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return LookupTags(ctx, p, tags)
+}
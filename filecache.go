@@ -0,0 +1,122 @@
+package coverart
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The FileCache is a Cache storing every result in its own file of a
+// directory, so the results survive restarts. It is safe for concurrent use,
+// including by several processes sharing the same directory.
+type FileCache struct {
+	dir string
+	ttl time.Duration
+}
+
+type fileEntry struct {
+	Key        string      `json:"key"`
+	Expires    time.Time   `json:"expires"`
+	Result     Result      `json:"result"`
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// NewFileCache returns a FileCache storing its results in dir, each of them
+// for the ttl duration. The directory is created if needed. Results never
+// expire when ttl is zero.
+func NewFileCache(dir string, ttl time.Duration) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileCache{dir, ttl}, nil
+}
+
+// Used to get the path of the file holding the result of the key
+func (c *FileCache) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the result stored for the key, expired results are removed
+func (c *FileCache) Get(key string) (Result, bool) {
+	entry, ok := c.read(key)
+	return entry.Result, ok
+}
+
+// GetCandidates returns the candidates stored for the key, expired candidates
+// are removed
+func (c *FileCache) GetCandidates(key string) ([]Candidate, bool) {
+	entry, ok := c.read(key)
+	return entry.Candidates, ok
+}
+
+// Set stores the result for the key. Failures to write the file are ignored,
+// the result would only be looked up again.
+func (c *FileCache) Set(key string, res Result) {
+	c.write(fileEntry{Key: key, Result: res})
+}
+
+// SetCandidates stores the candidates for the key, failures are ignored like
+// with Set
+func (c *FileCache) SetCandidates(key string, candidates []Candidate) {
+	c.write(fileEntry{Key: key, Candidates: candidates})
+}
+
+// Used to read the entry of the key, expired entries are removed
+func (c *FileCache) read(key string) (fileEntry, bool) {
+	path := c.path(key)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fileEntry{}, false
+	}
+
+	entry := fileEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return fileEntry{}, false
+	}
+
+	if !entry.Expires.IsZero() && time.Now().After(entry.Expires) {
+		os.Remove(path)
+		return fileEntry{}, false
+	}
+
+	return entry, true
+}
+
+// Used to write the entry into the file of its key
+func (c *FileCache) write(entry fileEntry) {
+	if c.ttl > 0 {
+		entry.Expires = time.Now().Add(c.ttl)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(entry.Key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
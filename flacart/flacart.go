@@ -0,0 +1,357 @@
+// Package flacart provides few helper methods to embed the artworks found by
+// the coverart packages into FLAC files, as a METADATA_BLOCK_PICTURE front
+// cover
+package flacart
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// FrontCover is the picture type of a front cover
+const FrontCover = 3
+
+// Size of the PADDING block which closes the metadata when the whole file has
+// to be rewritten, the next pictures and comments take their room from it
+// without moving the audio frames
+const padding = 4096
+
+// Largest length of a metadata block, stored on 24 bits
+const maxBlockSize = 1<<24 - 1
+
+// Types of the metadata blocks
+const (
+	blockPadding = 1
+	blockComment = 4
+	blockPicture = 6
+)
+
+// Error values returned by the helper methods
+var (
+	// ErrCoverExists is returned by Embed when one of the PICTURE blocks of
+	// the file is a front cover, and replace is false
+	ErrCoverExists = errors.New("The file already has a front cover")
+
+	// ErrInvalidFile is returned for the files which are not valid FLAC files
+	ErrInvalidFile = errors.New("Invalid FLAC file")
+)
+
+type block struct {
+	kind byte
+	data []byte
+}
+
+type metadata struct {
+	blocks []block
+
+	// start is the offset of the first block, after the "fLaC" marker, and
+	// end the offset of the audio frames
+	start int64
+	end   int64
+}
+
+// Used to read the metadata blocks of the file. An ID3v2 tag preceding the
+// FLAC stream is skipped.
+func readMetadata(r io.ReaderAt) (*metadata, error) {
+	m := &metadata{}
+	header := make([]byte, 10)
+
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, ErrInvalidFile
+	}
+
+	if string(header[:3]) == "ID3" {
+		size := int64(header[6]&0x7f)<<21 | int64(header[7]&0x7f)<<14 | int64(header[8]&0x7f)<<7 | int64(header[9]&0x7f)
+		m.start = 10 + size
+		if header[5]&0x10 != 0 {
+			m.start += 10
+		}
+	}
+
+	marker := make([]byte, 4)
+	if _, err := r.ReadAt(marker, m.start); err != nil || string(marker) != "fLaC" {
+		return nil, ErrInvalidFile
+	}
+
+	m.start += 4
+	offset := m.start
+
+	for last := false; !last; {
+		if _, err := r.ReadAt(header[:4], offset); err != nil {
+			return nil, ErrInvalidFile
+		}
+
+		last = header[0]&0x80 != 0
+		b := block{kind: header[0] & 0x7f}
+		b.data = make([]byte, int(header[1])<<16|int(header[2])<<8|int(header[3]))
+
+		if _, err := r.ReadAt(b.data, offset+4); err != nil {
+			return nil, ErrInvalidFile
+		}
+
+		m.blocks = append(m.blocks, b)
+		offset += 4 + int64(len(b.data))
+	}
+
+	m.end = offset
+	return m, nil
+}
+
+// Used to encode the blocks, followed by a padding block of the given size
+// when pad is not negative. A padding larger than the 24 bits length of the
+// blocks is split into several blocks taking the same room.
+func encodeBlocks(blocks []block, pad int) []byte {
+	for pad > maxBlockSize {
+		n := min(maxBlockSize, pad-4)
+		blocks = append(blocks, block{kind: blockPadding, data: make([]byte, n)})
+		pad -= n + 4
+	}
+
+	if pad >= 0 {
+		blocks = append(blocks, block{kind: blockPadding, data: make([]byte, pad)})
+	}
+
+	buf := bytes.Buffer{}
+	for i, b := range blocks {
+		kind := b.kind
+		if i == len(blocks)-1 {
+			kind |= 0x80
+		}
+
+		n := len(b.data)
+		buf.Write([]byte{kind, byte(n >> 16), byte(n >> 8), byte(n)})
+		buf.Write(b.data)
+	}
+
+	return buf.Bytes()
+}
+
+// Used to parse the picture type, MIME type and picture data of a picture
+// block
+func parsePicture(data []byte) (pictureType uint32, mimeType string, picture []byte, ok bool) {
+	r := bytes.NewReader(data)
+	var n uint32
+
+	field := func() []byte {
+		if binary.Read(r, binary.BigEndian, &n) != nil || int64(n) > int64(r.Len()) {
+			return nil
+		}
+
+		b := make([]byte, n)
+		r.Read(b)
+		return b
+	}
+
+	if binary.Read(r, binary.BigEndian, &pictureType) != nil {
+		return 0, "", nil, false
+	}
+
+	mime := field()
+	field() // description
+
+	// Width, height, depth and number of colors
+	if _, err := r.Seek(16, io.SeekCurrent); err != nil {
+		return 0, "", nil, false
+	}
+
+	picture = field()
+	if picture == nil {
+		return 0, "", nil, false
+	}
+
+	return pictureType, string(mime), picture, true
+}
+
+// Used to build a front cover picture block
+func pictureBlock(img *artwork.Image) (block, error) {
+	depth := uint32(24)
+	if img.Format == "png" {
+		depth = 32
+	}
+
+	mime := img.MIMEType()
+	buf := bytes.Buffer{}
+	fields := []interface{}{
+		uint32(FrontCover),
+		uint32(len(mime)), []byte(mime),
+		uint32(0), // empty description
+		uint32(img.Width), uint32(img.Height), depth, uint32(0),
+		uint32(len(img.Data)), img.Data,
+	}
+
+	for _, f := range fields {
+		binary.Write(&buf, binary.BigEndian, f)
+	}
+
+	if buf.Len() > maxBlockSize {
+		return block{}, artwork.ErrTooLarge
+	}
+
+	return block{kind: blockPicture, data: buf.Bytes()}, nil
+}
+
+// Used to check if the block is a front cover picture
+func (b block) frontCover() bool {
+	if b.kind != blockPicture {
+		return false
+	}
+
+	pictureType, _, _, ok := parsePicture(b.data)
+	return ok && pictureType == FrontCover
+}
+
+// Used to get the index of the first front cover block, -1 if there is none
+func (m *metadata) frontCover() int {
+	for i, b := range m.blocks {
+		if b.frontCover() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func open(path string) (*metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return readMetadata(f)
+}
+
+// HasCover reports if the FLAC file has a front cover picture block
+func HasCover(path string) (bool, error) {
+	m, err := open(path)
+	if err != nil {
+		return false, err
+	}
+
+	return m.frontCover() >= 0, nil
+}
+
+// ReadCover returns the picture data and MIME type of the front cover of the
+// file. The error matches artwork.ErrNotFound when there is none.
+func ReadCover(path string) ([]byte, string, error) {
+	m, err := open(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	i := m.frontCover()
+	if i < 0 {
+		return nil, "", artwork.NotFound("No front cover was found")
+	}
+
+	_, mimeType, picture, _ := parsePicture(m.blocks[i].data)
+	return picture, mimeType, nil
+}
+
+// ReadTags returns the album, artists and title of the Vorbis comment block of
+// the file. The tags are empty when the file has no Vorbis comment.
+func ReadTags(path string) (*artwork.Tags, error) {
+	m, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range m.blocks {
+		if b.kind == blockComment {
+			return artwork.ParseVorbisComment(b.data)
+		}
+	}
+
+	return &artwork.Tags{}, nil
+}
+
+// Embed writes the artwork into the FLAC file as a front cover picture block.
+// The other metadata blocks are preserved. The existing front covers are only
+// replaced when replace is true, all of them by the artwork, otherwise
+// ErrCoverExists is returned. The pictures over 16 MiB do not fit in a block,
+// artwork.ErrTooLarge is returned for them.
+//
+// The metadata is rewritten in place when the padding blocks leave enough room
+// for the artwork, so that the audio frames are not moved. Otherwise the whole
+// file is rewritten.
+func Embed(path string, img *artwork.Image, replace bool) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	m, err := readMetadata(f)
+	if err != nil {
+		return err
+	}
+
+	cover, err := pictureBlock(img)
+	if err != nil {
+		return err
+	}
+
+	if m.frontCover() >= 0 && !replace {
+		return ErrCoverExists
+	}
+
+	// Keep the blocks but the padding and the previous front covers, the
+	// first of which is replaced where it was
+	blocks := []block{}
+	added := false
+	for _, b := range m.blocks {
+		switch {
+		case b.kind == blockPadding:
+		case b.frontCover():
+			if !added {
+				blocks = append(blocks, cover)
+				added = true
+			}
+		default:
+			blocks = append(blocks, b)
+		}
+	}
+
+	if !added {
+		blocks = append(blocks, cover)
+	}
+
+	available := int(m.end - m.start)
+	size := len(encodeBlocks(blocks, -1))
+
+	switch {
+	case size == available:
+		_, err = f.WriteAt(encodeBlocks(blocks, -1), m.start)
+		return err
+	case size+4 <= available:
+		_, err = f.WriteAt(encodeBlocks(blocks, available-size-4), m.start)
+		return err
+	}
+
+	// The metadata is written between what precedes it and the audio frames,
+	// in a new file
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return artwork.ReplaceFile(path, func(w io.Writer) error {
+		if _, err := io.Copy(w, io.NewSectionReader(f, 0, m.start)); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(encodeBlocks(blocks, padding)); err != nil {
+			return err
+		}
+
+		_, err := io.Copy(w, io.NewSectionReader(f, m.end, info.Size()-m.end))
+		return err
+	})
+}
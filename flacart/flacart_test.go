@@ -0,0 +1,288 @@
+package flacart_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/flacart"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var audio = bytes.Repeat([]byte{0xff, 0xf8, 0xc9, 0x18}, 256)
+
+// flacFile builds a FLAC file with a stream info block, a padding block of the
+// given size and the audio frames
+func flacFile(pad int) []byte {
+	data := []byte("fLaC")
+	data = append(data, 0x00, 0, 0, 34)
+	data = append(data, make([]byte, 34)...)
+	data = append(data, 0x81, byte(pad>>16), byte(pad>>8), byte(pad))
+	data = append(data, make([]byte, pad)...)
+
+	return append(data, audio...)
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "track.flac")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return path
+}
+
+func readFile(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return data
+}
+
+func TestEmbed(t *testing.T) {
+	cover := &artwork.Image{Data: []byte("jpeg data"), Format: "jpeg", Width: 500, Height: 500}
+
+	for _, pad := range []int{0, 1024} {
+		path := writeFile(t, flacFile(pad))
+
+		if err := flacart.Embed(path, cover, false); err != nil {
+			t.Fatalf("padding %d: unexpected error %v", pad, err)
+		}
+
+		data, mimeType, err := flacart.ReadCover(path)
+		if err != nil || !bytes.Equal(data, cover.Data) || mimeType != "image/jpeg" {
+			t.Errorf("padding %d: unexpected cover %q %s %v", pad, data, mimeType, err)
+		}
+
+		file, _ := ioutil.ReadFile(path)
+		if !bytes.HasSuffix(file, audio) {
+			t.Errorf("padding %d: the audio frames were not preserved", pad)
+		}
+
+		// The padding left enough room to write the picture in place
+		if pad > 0 && len(file) != len(flacFile(pad)) {
+			t.Errorf("padding %d: expected the file size to be kept, got %d", pad, len(file))
+		}
+
+		if err := flacart.Embed(path, cover, false); !errors.Is(err, flacart.ErrCoverExists) {
+			t.Errorf("padding %d: expected the existing cover to be kept, got %v", pad, err)
+		}
+	}
+}
+
+type metadataBlock struct {
+	kind byte
+	size int
+}
+
+// metadataBlocks lists the type and size of the metadata blocks of the file,
+// headers included
+func metadataBlocks(t *testing.T, file []byte) []metadataBlock {
+	blocks := []metadataBlock{}
+	for data := file[4:]; ; {
+		if len(data) < 4 {
+			t.Fatalf("truncated metadata")
+		}
+
+		b := metadataBlock{data[0] & 0x7f, 4 + (int(data[1])<<16 | int(data[2])<<8 | int(data[3]))}
+		blocks = append(blocks, b)
+
+		if data[0]&0x80 != 0 {
+			return blocks
+		}
+
+		data = data[b.size:]
+	}
+}
+
+func TestEmbedPadding(t *testing.T) {
+	cover := &artwork.Image{Data: bytes.Repeat([]byte("jpeg data"), 32), Format: "jpeg"}
+
+	// The size of the picture block, header included
+	path := writeFile(t, flacFile(0))
+	flacart.Embed(path, cover, false)
+	file, _ := ioutil.ReadFile(path)
+	picture := metadataBlocks(t, file)[1].size
+
+	tests := []struct {
+		name     string
+		pad      int
+		inPlace  bool
+		expected int
+	}{
+		{"no padding", 0, false, 4096},
+		{"exact fit", picture - 4, true, -1},
+		{"larger padding", picture + 100, true, 100},
+		{"no room for a padding block", picture - 2, false, 4096},
+	}
+
+	for _, test := range tests {
+		path := writeFile(t, flacFile(test.pad))
+		if err := flacart.Embed(path, cover, false); err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+
+		file, _ := ioutil.ReadFile(path)
+		if inPlace := len(file) == len(flacFile(test.pad)); inPlace != test.inPlace || !bytes.HasSuffix(file, audio) {
+			t.Errorf("%s: expected the metadata to be written in place: %v", test.name, test.inPlace)
+		}
+
+		// The padding block, when any, closes the metadata
+		blocks := metadataBlocks(t, file)
+		last := blocks[len(blocks)-1]
+		switch {
+		case test.expected < 0 && (len(blocks) != 2 || last.kind != 6):
+			t.Errorf("%s: expected no padding block, got %v", test.name, blocks)
+		case test.expected >= 0 && (len(blocks) != 3 || last.kind != 1 || last.size != 4+test.expected):
+			t.Errorf("%s: expected a padding of %d bytes, got %v", test.name, test.expected, blocks)
+		}
+	}
+
+	// A larger cover takes its room from the padding added by a rewrite
+	path = writeFile(t, flacFile(0))
+	flacart.Embed(path, cover, false)
+	size := len(readFile(t, path))
+
+	larger := &artwork.Image{Data: bytes.Repeat([]byte("jpeg data"), 64), Format: "jpeg"}
+	if err := flacart.Embed(path, larger, true); err != nil || len(readFile(t, path)) != size {
+		t.Errorf("expected the larger cover to be written in place, got %v", err)
+	}
+
+	if data, _, err := flacart.ReadCover(path); !bytes.Equal(data, larger.Data) || err != nil {
+		t.Errorf("unexpected cover %q %v", data, err)
+	}
+}
+
+// flacBlocks builds a FLAC file with a stream info block followed by the
+// blocks, given as their type and data, and the audio frames
+func flacBlocks(blocks ...[]byte) []byte {
+	data := append([]byte("fLaC\x00\x00\x00\x22"), make([]byte, 34)...)
+	for i, b := range blocks {
+		kind, n := b[0], len(b)-1
+		if i == len(blocks)-1 {
+			kind |= 0x80
+		}
+
+		data = append(append(data, kind, byte(n>>16), byte(n>>8), byte(n)), b[1:]...)
+	}
+
+	return append(data, audio...)
+}
+
+// picture builds the type and data of a picture block
+func picture(pictureType uint32, data string) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{6}, pictureType)
+	b = binary.BigEndian.AppendUint32(b, 9)
+	b = append(b, "image/png"...)
+	b = append(b, make([]byte, 20)...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+
+	return append(b, data...)
+}
+
+func TestEmbedFrontCovers(t *testing.T) {
+	// Some taggers write a front cover per size, all of them are replaced
+	path := writeFile(t, flacBlocks(picture(flacart.FrontCover, "small"), picture(0, "other"), picture(flacart.FrontCover, "large")))
+	if err := flacart.Embed(path, &artwork.Image{Data: []byte("cover"), Format: "jpeg"}, true); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	file := readFile(t, path)
+	if bytes.Contains(file, []byte("small")) || bytes.Contains(file, []byte("large")) || !bytes.Contains(file, []byte("other")) {
+		t.Errorf("expected the front covers to be replaced and the other pictures kept")
+	}
+
+	if blocks := metadataBlocks(t, file); blocks[1].kind != 6 || blocks[2].kind != 6 || len(blocks) > 3 && blocks[3].kind == 6 {
+		t.Errorf("expected the cover in place of the first front cover, got %v", blocks)
+	}
+
+	// The pictures which do not fit in a block are rejected
+	large := &artwork.Image{Data: make([]byte, 1<<24), Format: "jpeg"}
+	if err := flacart.Embed(path, large, true); !errors.Is(err, artwork.ErrTooLarge) {
+		t.Errorf("expected a too large error, got %v", err)
+	}
+}
+
+func TestEmbedLargePadding(t *testing.T) {
+	// The room left by a large cover exceeds the length of a block
+	pad := append([]byte{1}, make([]byte, 9<<20)...)
+	original := flacBlocks(pad, pad)
+	path := writeFile(t, original)
+
+	if err := flacart.Embed(path, &artwork.Image{Data: []byte("cover"), Format: "jpeg"}, false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	file := readFile(t, path)
+	if len(file) != len(original) || !bytes.HasSuffix(file, audio) {
+		t.Fatalf("expected the metadata to be written in place")
+	}
+
+	size := 0
+	for _, b := range metadataBlocks(t, file)[2:] {
+		if b.kind != 1 || b.size > 4+1<<24-1 {
+			t.Errorf("unexpected padding block %v", b)
+		}
+
+		size += b.size
+	}
+
+	if blocks := metadataBlocks(t, file); 4+38+blocks[1].size+size+len(audio) != len(file) {
+		t.Errorf("expected the padding to fill the metadata, got %v", blocks)
+	}
+
+	if data, _, err := flacart.ReadCover(path); string(data) != "cover" || err != nil {
+		t.Errorf("unexpected cover %q %v", data, err)
+	}
+}
+
+func TestEmbedReplace(t *testing.T) {
+	path := writeFile(t, flacFile(0))
+
+	flacart.Embed(path, &artwork.Image{Data: []byte("first"), Format: "png"}, false)
+	if err := flacart.Embed(path, &artwork.Image{Data: []byte("second"), Format: "jpeg"}, true); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	data, mimeType, _ := flacart.ReadCover(path)
+	if string(data) != "second" || mimeType != "image/jpeg" {
+		t.Errorf("expected the cover to be replaced, got %q %s", data, mimeType)
+	}
+
+	if ok, err := flacart.HasCover(path); !ok || err != nil {
+		t.Errorf("expected the file to have a cover, got %v", err)
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	path := writeFile(t, audio)
+
+	if _, err := flacart.HasCover(path); !errors.Is(err, flacart.ErrInvalidFile) {
+		t.Errorf("expected an invalid file error, got %v", err)
+	}
+}
+
+func TestReadTags(t *testing.T) {
+	comment := []byte{6, 0, 0, 0, 'v', 'e', 'n', 'd', 'o', 'r', 2, 0, 0, 0}
+	for _, f := range []string{"ALBUM=Halcyon", "ARTIST=Ellie Goulding"} {
+		comment = append(append(comment, byte(len(f)), 0, 0, 0), f...)
+	}
+
+	// A stream info block, followed by the last block holding the comment
+	data := append([]byte("fLaC\x00\x00\x00\x22"), make([]byte, 34)...)
+	data = append(data, 0x80|4, 0, 0, byte(len(comment)))
+	data = append(append(data, comment...), audio...)
+
+	tags, err := flacart.ReadTags(writeFile(t, data))
+	if err != nil || *tags != (artwork.Tags{Album: "Halcyon", Artist: "Ellie Goulding"}) {
+		t.Errorf("unexpected tags %+v %v", tags, err)
+	}
+
+	if tags, err := flacart.ReadTags(writeFile(t, flacFile(0))); err != nil || !tags.Empty() {
+		t.Errorf("expected empty tags, got %+v %v", tags, err)
+	}
+}
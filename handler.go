@@ -0,0 +1,304 @@
+package coverart
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// Response modes of the Handler, selected with the mode query parameter
+const (
+	// JSONMode returns the Result as JSON
+	JSONMode = "json"
+
+	// RedirectMode redirects to the largest artwork of the Result
+	RedirectMode = "redirect"
+
+	// ProxyMode downloads the largest artwork of the Result and returns its
+	// bytes, optionally resized with the size and format query parameters
+	ProxyMode = "proxy"
+)
+
+// The Handler exposes a Provider as an HTTP service. The /album, /track and
+// /artist endpoints take the album, track and artist query parameters and look
+// up the artwork with the provider:
+//
+//	GET /album?album=halcyon+days&artist=ellie+goulding
+//	GET /track?track=figure+8&artist=ellie+goulding&mode=redirect
+//	GET /artist?artist=ellie+goulding&mode=proxy&size=300&format=jpeg
+//
+// Only the last element of the path is matched, so that the handler can be
+// mounted under any prefix. The errors are returned as JSON as well, with the
+// HTTP status matching the error of the provider. The rejected credentials of
+// the provider are reported as an internal error, and logged.
+type Handler struct {
+	Provider Provider
+
+	// Fetcher downloads the artworks in proxy mode, the default Fetcher is
+	// used when nil
+	Fetcher *artwork.Fetcher
+
+	// MaxAge is the duration the successful responses can be cached by the
+	// clients
+	MaxAge time.Duration
+
+	// Timeout is the deadline of every request, no deadline is added when it
+	// is zero
+	Timeout time.Duration
+
+	// ErrorLog logs the errors which are not caused by the requests, the
+	// standard logger is used when nil
+	ErrorLog *log.Logger
+}
+
+// NewHandler returns a Handler looking up the artworks with the provider, the
+// responses can be cached for a day
+func NewHandler(p Provider) *Handler {
+	return &Handler{Provider: p, MaxAge: 24 * time.Hour}
+}
+
+// errBadRequest is returned when the query parameters are invalid
+var errBadRequest = errors.New("Bad request")
+
+// Used to run the lookup of the endpoint with the query parameters
+func (h *Handler) lookup(ctx context.Context, r *http.Request) (Result, error) {
+	q := r.URL.Query()
+	album, track, artist := q.Get("album"), q.Get("track"), q.Get("artist")
+
+	switch endpoint := path.Base(r.URL.Path); {
+	case endpoint == "album" && len(album) > 0:
+		return h.Provider.Album(ctx, album, artist)
+	case endpoint == "track" && len(track) > 0:
+		return h.Provider.Track(ctx, track, artist)
+	case endpoint == "artist" && len(artist) > 0:
+		return h.Provider.Artist(ctx, artist)
+	case endpoint == "album" || endpoint == "track" || endpoint == "artist":
+		return Result{}, fmt.Errorf("%w: the %s parameter is required", errBadRequest, endpoint)
+	}
+
+	return Result{}, artwork.NotFound("Unknown endpoint " + r.URL.Path)
+}
+
+// Used to write the error as JSON, with its HTTP status
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	var rateErr *artwork.RateLimitError
+
+	switch {
+	case errors.Is(err, errBadRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, artwork.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.As(err, &rateErr):
+		status = http.StatusServiceUnavailable
+		if rateErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(rateErr.RetryAfter.Seconds()+0.5)))
+		}
+	case errors.Is(err, artwork.ErrRateLimited):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, artwork.ErrUnauthorized):
+		// The credentials of the provider are not the client's fault
+		status = http.StatusInternalServerError
+		h.logf("coverart: the provider rejected its credentials: %v", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// Used to log with the ErrorLog of the handler, or the standard logger
+func (h *Handler) logf(format string, args ...interface{}) {
+	if h.ErrorLog != nil {
+		h.ErrorLog.Printf(format, args...)
+		return
+	}
+
+	log.Printf(format, args...)
+}
+
+// Used to set the caching headers of the successful responses
+func (h *Handler) cache(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.MaxAge.Seconds())))
+}
+
+// Used to parse the size and format query parameters, nil when the artwork
+// is not resized
+func resizeOptions(r *http.Request) (*artwork.ResizeOptions, error) {
+	q := r.URL.Query()
+	if len(q.Get("size")) == 0 && len(q.Get("format")) == 0 {
+		return nil, nil
+	}
+
+	opts := &artwork.ResizeOptions{Format: q.Get("format")}
+	if len(q.Get("size")) > 0 {
+		size, err := strconv.Atoi(q.Get("size"))
+		if err != nil || size <= 0 || size > artwork.MaxSize {
+			return nil, fmt.Errorf("%w: invalid size %q", errBadRequest, q.Get("size"))
+		}
+
+		opts.Size = size
+	}
+
+	if len(opts.Format) > 0 && opts.Format != "jpeg" && opts.Format != "png" {
+		return nil, fmt.Errorf("%w: invalid format %q", errBadRequest, opts.Format)
+	}
+
+	return opts, nil
+}
+
+// Used to download the artwork of the result, resized with the options when
+// they are not nil
+func (h *Handler) fetch(ctx context.Context, res Result, opts *artwork.ResizeOptions) (*artwork.Image, error) {
+	fetcher := h.Fetcher
+	if fetcher == nil {
+		fetcher = artwork.NewFetcher()
+	}
+
+	img, err := fetcher.Fetch(ctx, res.URL())
+	if err != nil || opts == nil {
+		return img, err
+	}
+
+	return artwork.Resize(img, *opts)
+}
+
+// Used to derive the ETag of a proxied artwork from its url and the resize
+// options, so that it is known before downloading the artwork
+func etag(res Result, opts *artwork.ResizeOptions) string {
+	key := res.URL()
+	if opts != nil {
+		key += fmt.Sprintf("|%d|%s", opts.Size, opts.Format)
+	}
+
+	sum := sha1.Sum([]byte(key))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// Used to check if the If-None-Match header of the request matches the ETag
+func notModified(r *http.Request, etag string) bool {
+	for _, v := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Used to guess the media type of a proxied artwork without downloading it,
+// empty when it is unknown
+func mimeType(res Result, opts *artwork.ResizeOptions) string {
+	format := artwork.FormatOf(res.URL())
+	if opts != nil && len(opts.Format) > 0 {
+		format = opts.Format
+	}
+
+	if len(format) == 0 {
+		return ""
+	}
+
+	return "image/" + format
+}
+
+// ServeHTTP looks up the artwork of the request and writes the response in the
+// mode of the request, JSON by default
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if len(mode) > 0 && mode != JSONMode && mode != RedirectMode && mode != ProxyMode {
+		h.writeError(w, fmt.Errorf("%w: unknown mode %q", errBadRequest, mode))
+		return
+	}
+
+	opts, err := resizeOptions(r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	res, err := h.lookup(ctx, r)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	switch mode {
+	case RedirectMode:
+		if len(res.URL()) == 0 {
+			h.writeError(w, artwork.NotFound("No artwork was found"))
+			return
+		}
+
+		h.cache(w)
+		http.Redirect(w, r, res.URL(), http.StatusFound)
+	case ProxyMode:
+		if len(res.URL()) == 0 {
+			h.writeError(w, artwork.NotFound("No artwork was found"))
+			return
+		}
+
+		// The artwork is only downloaded when the client does not have it
+		// and wants its body
+		tag := etag(res, opts)
+		if notModified(r, tag) {
+			h.cache(w)
+			w.Header().Set("ETag", tag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if r.Method == http.MethodHead {
+			h.cache(w)
+			w.Header().Set("ETag", tag)
+			if mime := mimeType(res, opts); len(mime) > 0 {
+				w.Header().Set("Content-Type", mime)
+			}
+
+			return
+		}
+
+		img, err := h.fetch(ctx, res, opts)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+
+		h.cache(w)
+		w.Header().Set("ETag", tag)
+		w.Header().Set("Content-Type", img.MIMEType())
+		w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+		w.Write(img.Data)
+	default:
+		h.cache(w)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
@@ -0,0 +1,448 @@
+// Package id3art provides few helper methods to embed the artworks found by
+// the coverart packages into the ID3v2.3 and ID3v2.4 tags of MP3 files, as an
+// APIC front cover frame
+package id3art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// FrontCover is the APIC picture type of a front cover
+const FrontCover = 3
+
+// Number of zero bytes left after the frames when the whole file has to be
+// rewritten, so that the tag can grow in place the next time
+const padding = 2048
+
+const (
+	flagUnsync   = 0x80
+	flagExtended = 0x40
+	flagFooter   = 0x10
+
+	// Format flags of the ID3v2.4 frames
+	frameUnsync = 0x02
+)
+
+// Error values returned by the helper methods
+var (
+	// ErrCoverExists is returned by Embed when the ID3v2 tag already has an
+	// APIC frame of the front cover type, and replace is false
+	ErrCoverExists = errors.New("The file already has a front cover")
+
+	// ErrUnsupportedVersion is returned for the tags which are neither ID3v2.3
+	// nor ID3v2.4
+	ErrUnsupportedVersion = errors.New("Unsupported ID3v2 version")
+)
+
+type frame struct {
+	id    string
+	flags [2]byte
+	data  []byte
+}
+
+type tag struct {
+	version byte
+	frames  []frame
+
+	// size is the total size of the tag in the file, header and footer
+	// included, zero when the file has no tag
+	size int64
+}
+
+// Used to decode the 28 bits integers of the ID3v2 headers
+func syncsafe(b []byte) int64 {
+	return int64(b[0]&0x7f)<<21 | int64(b[1]&0x7f)<<14 | int64(b[2]&0x7f)<<7 | int64(b[3]&0x7f)
+}
+
+// Used to encode the 28 bits integers of the ID3v2 headers
+func putSyncsafe(b []byte, v int) {
+	b[0] = byte(v>>21) & 0x7f
+	b[1] = byte(v>>14) & 0x7f
+	b[2] = byte(v>>7) & 0x7f
+	b[3] = byte(v) & 0x7f
+}
+
+// Used to revert the unsynchronisation scheme, which inserts a zero byte after
+// every 0xFF byte
+func unsync(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xff && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+
+	return out
+}
+
+// Used to read the ID3v2 tag at the beginning of the file, an empty ID3v2.3
+// tag is returned when there is none
+func readTag(r io.ReaderAt) (*tag, error) {
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, 0); err != nil || string(header[:3]) != "ID3" {
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		return &tag{version: 3}, nil
+	}
+
+	t := &tag{version: header[3], size: 10 + syncsafe(header[6:])}
+	if t.version != 3 && t.version != 4 {
+		return nil, fmt.Errorf("%w: ID3v2.%d", ErrUnsupportedVersion, t.version)
+	}
+
+	flags := header[5]
+	if flags&flagFooter != 0 {
+		t.size += 10
+	}
+
+	body := make([]byte, syncsafe(header[6:]))
+	if _, err := r.ReadAt(body, 10); err != nil {
+		return nil, err
+	}
+
+	if t.version == 3 && flags&flagUnsync != 0 {
+		body = unsync(body)
+	}
+
+	if flags&flagExtended != 0 && len(body) >= 4 {
+		if t.version == 3 {
+			body = body[min(len(body), 4+int(binary.BigEndian.Uint32(body))):]
+		} else {
+			body = body[min(len(body), int(syncsafe(body))):]
+		}
+	}
+
+	for len(body) >= 10 && body[0] != 0 {
+		f := frame{id: string(body[:4]), flags: [2]byte{body[8], body[9]}}
+
+		size := int64(binary.BigEndian.Uint32(body[4:]))
+		if t.version == 4 {
+			size = syncsafe(body[4:])
+		}
+
+		if size > int64(len(body)-10) {
+			return nil, fmt.Errorf("Invalid size of the %s frame", f.id)
+		}
+
+		f.data = body[10 : 10+size]
+		body = body[10+size:]
+
+		if t.version == 4 && (f.flags[1]&frameUnsync != 0 || flags&flagUnsync != 0) {
+			f.data = unsync(f.data)
+			f.flags[1] &^= frameUnsync
+		}
+
+		t.frames = append(t.frames, f)
+	}
+
+	return t, nil
+}
+
+// Used to encode the tag, followed by the given amount of padding. The flags
+// of the header are cleared, as the extended header and footer are not kept
+// and the frames are not unsynchronised.
+func (t *tag) encode(pad int) []byte {
+	body := bytes.Buffer{}
+
+	for _, f := range t.frames {
+		header := make([]byte, 10)
+		copy(header, f.id)
+
+		if t.version == 4 {
+			putSyncsafe(header[4:], len(f.data))
+		} else {
+			binary.BigEndian.PutUint32(header[4:], uint32(len(f.data)))
+		}
+
+		header[8], header[9] = f.flags[0], f.flags[1]
+		body.Write(header)
+		body.Write(f.data)
+	}
+
+	body.Write(make([]byte, pad))
+
+	header := []byte{'I', 'D', '3', t.version, 0, 0, 0, 0, 0, 0}
+	putSyncsafe(header[6:], body.Len())
+
+	return append(header, body.Bytes()...)
+}
+
+// Used to parse the MIME type and picture type of an APIC frame, and its
+// picture data
+func parsePicture(data []byte) (mimeType string, pictureType byte, picture []byte, ok bool) {
+	if len(data) < 2 {
+		return "", 0, nil, false
+	}
+
+	encoding := data[0]
+	end := bytes.IndexByte(data[1:], 0)
+	if end < 0 || len(data) < end+3 {
+		return "", 0, nil, false
+	}
+
+	mimeType = string(data[1 : 1+end])
+	pictureType = data[2+end]
+	desc := data[3+end:]
+
+	// The description is terminated by a null character, two bytes wide in
+	// the UTF-16 encodings
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(desc); i += 2 {
+			if desc[i] == 0 && desc[i+1] == 0 {
+				return mimeType, pictureType, desc[i+2:], true
+			}
+		}
+
+		return "", 0, nil, false
+	}
+
+	i := bytes.IndexByte(desc, 0)
+	if i < 0 {
+		return "", 0, nil, false
+	}
+
+	return mimeType, pictureType, desc[i+1:], true
+}
+
+// Used to decode the first string of a text frame
+func parseText(data []byte) string {
+	if len(data) < 1 {
+		return ""
+	}
+
+	encoding, data := data[0], data[1:]
+	var text string
+
+	switch encoding {
+	case 0: // ISO-8859-1
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+
+		text = string(runes)
+	case 1, 2: // UTF-16 with a byte order mark, UTF-16BE without
+		order := binary.ByteOrder(binary.BigEndian)
+		if encoding == 1 && len(data) >= 2 {
+			if data[0] == 0xff && data[1] == 0xfe {
+				order = binary.LittleEndian
+			}
+
+			data = data[2:]
+		}
+
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+
+		text = string(utf16.Decode(units))
+	default: // UTF-8
+		text = string(data)
+	}
+
+	// The values of the ID3v2.4 frames are separated by null characters
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// Used to build the data of an APIC front cover frame
+func pictureFrame(img *artwork.Image) frame {
+	data := bytes.Buffer{}
+	data.WriteByte(0) // ISO-8859-1 encoding
+	data.WriteString(img.MIMEType())
+	data.WriteByte(0)
+	data.WriteByte(FrontCover)
+	data.WriteByte(0) // empty description
+	data.Write(img.Data)
+
+	return frame{id: "APIC", data: data.Bytes()}
+}
+
+// Used to check if the frame is an APIC front cover frame
+func (f frame) frontCover() bool {
+	if f.id != "APIC" {
+		return false
+	}
+
+	_, pictureType, _, ok := parsePicture(f.data)
+	return ok && pictureType == FrontCover
+}
+
+// Used to get the index of the first front cover frame, -1 if there is none
+func (t *tag) frontCover() int {
+	for i, f := range t.frames {
+		if f.frontCover() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Used to replace every front cover frame of the tag by the given one, which
+// takes the place of the first of them. It is appended when the tag has none.
+func (t *tag) replaceCover(cover frame) {
+	frames := []frame{}
+	found := false
+
+	for _, f := range t.frames {
+		if !f.frontCover() {
+			frames = append(frames, f)
+			continue
+		}
+
+		if !found {
+			frames = append(frames, cover)
+			found = true
+		}
+	}
+
+	if !found {
+		frames = append(frames, cover)
+	}
+
+	t.frames = frames
+}
+
+// HasCover reports if the ID3v2 tag of the file has a front cover
+func HasCover(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+
+	t, err := readTag(f)
+	if err != nil {
+		return false, err
+	}
+
+	return t.frontCover() >= 0, nil
+}
+
+// ReadCover returns the picture data and MIME type of the front cover of the
+// file. The error matches artwork.ErrNotFound when there is none.
+func ReadCover(path string) ([]byte, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer f.Close()
+
+	t, err := readTag(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	i := t.frontCover()
+	if i < 0 {
+		return nil, "", artwork.NotFound("No front cover was found")
+	}
+
+	mimeType, _, picture, _ := parsePicture(t.frames[i].data)
+	return picture, mimeType, nil
+}
+
+// ReadTags returns the album, artists and title of the ID3v2 tag of the file,
+// from the TALB, TPE2, TPE1 and TIT2 frames. The tags are empty when the file
+// has no ID3v2 tag.
+func ReadTags(path string) (*artwork.Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	t, err := readTag(f)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := &artwork.Tags{}
+	fields := map[string]*string{
+		"TALB": &tags.Album,
+		"TPE2": &tags.AlbumArtist,
+		"TPE1": &tags.Artist,
+		"TIT2": &tags.Title,
+	}
+
+	for _, fr := range t.frames {
+		if dst, ok := fields[fr.id]; ok && len(*dst) == 0 {
+			*dst = parseText(fr.data)
+		}
+	}
+
+	return tags, nil
+}
+
+// Embed writes the artwork into the ID3v2 tag of the MP3 file as an APIC front
+// cover frame. The other frames are preserved, and a tag is created if the
+// file has none. The existing front covers are only replaced when replace is
+// true, all of them by the artwork, otherwise ErrCoverExists is returned.
+//
+// The tag is written without extended header, footer nor unsynchronisation,
+// and its header flags are cleared accordingly. The space of the footer is
+// reused as padding.
+//
+// The tag is rewritten in place when its padding leaves enough room for the
+// artwork, otherwise the whole file is rewritten.
+func Embed(path string, img *artwork.Image, replace bool) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	t, err := readTag(f)
+	if err != nil {
+		return err
+	}
+
+	if t.frontCover() >= 0 && !replace {
+		return ErrCoverExists
+	}
+
+	t.replaceCover(pictureFrame(img))
+
+	data := t.encode(0)
+	if int64(len(data)) <= t.size {
+		data = t.encode(int(t.size) - len(data))
+		_, err = f.WriteAt(data, 0)
+		return err
+	}
+
+	// The audio data follows the new tag in a new file
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return artwork.ReplaceFile(path, func(w io.Writer) error {
+		if _, err := w.Write(t.encode(padding)); err != nil {
+			return err
+		}
+
+		_, err := io.Copy(w, io.NewSectionReader(f, t.size, info.Size()-t.size))
+		return err
+	})
+}
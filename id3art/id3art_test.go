@@ -0,0 +1,255 @@
+package id3art_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/piraveen/go-coverart"
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/id3art"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var audio = bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x64}, 256)
+
+// id3Tag builds an ID3v2 tag holding a title frame, followed by the padding
+func id3Tag(version byte, pad int) []byte {
+	title := append([]byte{0}, "Halcyon Days"...)
+	size := len(title)
+
+	frame := []byte{'T', 'I', 'T', '2', 0, 0, byte(size >> 7), byte(size & 0x7f), 0, 0}
+	if version == 3 {
+		frame[6], frame[7] = byte(size>>8), byte(size)
+	}
+
+	body := append(append(frame, title...), make([]byte, pad)...)
+	n := len(body)
+	header := []byte{'I', 'D', '3', version, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+
+	return append(header, body...)
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "track.mp3")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return path
+}
+
+func TestEmbed(t *testing.T) {
+	cover := &artwork.Image{Data: []byte("jpeg data"), Format: "jpeg"}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		title bool
+	}{
+		{"no tag", audio, false},
+		{"ID3v2.3", append(id3Tag(3, 0), audio...), true},
+		{"ID3v2.4", append(id3Tag(4, 0), audio...), true},
+	}
+
+	for _, test := range tests {
+		path := writeFile(t, test.data)
+
+		if err := id3art.Embed(path, cover, false); err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+
+		data, mimeType, err := id3art.ReadCover(path)
+		if err != nil || !bytes.Equal(data, cover.Data) || mimeType != "image/jpeg" {
+			t.Errorf("%s: unexpected cover %q %s %v", test.name, data, mimeType, err)
+		}
+
+		file, _ := ioutil.ReadFile(path)
+		if !bytes.HasSuffix(file, audio) {
+			t.Errorf("%s: the audio data was not preserved", test.name)
+		}
+
+		if test.title && !bytes.Contains(file, []byte("Halcyon Days")) {
+			t.Errorf("%s: the title frame was not preserved", test.name)
+		}
+
+		if err := id3art.Embed(path, cover, false); !errors.Is(err, id3art.ErrCoverExists) {
+			t.Errorf("%s: expected the existing cover to be kept, got %v", test.name, err)
+		}
+	}
+}
+
+func TestEmbedReplace(t *testing.T) {
+	path := writeFile(t, append(id3Tag(4, 4096), audio...))
+	size := len(id3Tag(4, 4096)) + len(audio)
+
+	id3art.Embed(path, &artwork.Image{Data: []byte("first"), Format: "png"}, false)
+	if err := id3art.Embed(path, &artwork.Image{Data: []byte("second"), Format: "jpeg"}, true); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	data, mimeType, _ := id3art.ReadCover(path)
+	if string(data) != "second" || mimeType != "image/jpeg" {
+		t.Errorf("expected the cover to be replaced, got %q %s", data, mimeType)
+	}
+
+	// The padding left enough room to update the tag in place
+	file, _ := ioutil.ReadFile(path)
+	if len(file) != size || bytes.Count(file, []byte("APIC")) != 1 {
+		t.Errorf("expected a single cover written in place, got %d bytes", len(file))
+	}
+
+	if ok, err := id3art.HasCover(path); !ok || err != nil {
+		t.Errorf("expected the file to have a cover, got %v", err)
+	}
+}
+
+// id3Frame builds an ID3v2 frame, whose size is syncsafe in ID3v2.4
+func id3Frame(version byte, id string, data []byte) []byte {
+	size := len(data)
+	frame := append([]byte(id), 0, byte(size>>14&0x7f), byte(size>>7&0x7f), byte(size&0x7f), 0, 0)
+	if version == 3 {
+		frame[5], frame[6], frame[7] = byte(size>>16), byte(size>>8), byte(size)
+	}
+
+	return append(frame, data...)
+}
+
+// withFrames inserts the frames at the beginning of the tag
+func withFrames(tag []byte, frames ...[]byte) []byte {
+	body := append(bytes.Join(frames, nil), tag[10:]...)
+	n := len(body)
+	header := append(tag[:6:6], byte(n>>21&0x7f), byte(n>>14&0x7f), byte(n>>7&0x7f), byte(n&0x7f))
+
+	return append(header, body...)
+}
+
+func TestEmbedFrontCovers(t *testing.T) {
+	picture := func(pictureType byte, data string) []byte {
+		return id3Frame(3, "APIC", append([]byte("\x00image/png\x00"+string(pictureType)+"\x00"), data...))
+	}
+
+	// Some taggers write a front cover per size, all of them are replaced
+	tag := withFrames(id3Tag(3, 0), picture(id3art.FrontCover, "small"), picture(0, "other"), picture(id3art.FrontCover, "large"))
+	path := writeFile(t, append(tag, audio...))
+
+	if err := id3art.Embed(path, &artwork.Image{Data: []byte("cover"), Format: "jpeg"}, true); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	file, _ := ioutil.ReadFile(path)
+	if bytes.Contains(file, []byte("small")) || bytes.Contains(file, []byte("large")) || !bytes.Contains(file, []byte("other")) {
+		t.Errorf("expected the front covers to be replaced and the other pictures kept")
+	}
+
+	if data, _, err := id3art.ReadCover(path); string(data) != "cover" || err != nil {
+		t.Errorf("unexpected cover %q %v", data, err)
+	}
+}
+
+func TestEmbedHeaderFlags(t *testing.T) {
+	// The ID3v2.3 extended header size excludes itself, the ID3v2.4 one
+	// includes itself and is syncsafe
+	extended := func(version byte) []byte {
+		tag := id3Tag(version, 64)
+		tag[5] = 0x40
+		if version == 3 {
+			return withFrames(tag, []byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 0})
+		}
+
+		return withFrames(tag, []byte{0, 0, 0, 6, 1, 0})
+	}
+
+	footer := id3Tag(4, 0)
+	footer[5] = 0x10
+	footer = append(footer, '3', 'D', 'I', 4, 0, 0x10, footer[6], footer[7], footer[8], footer[9])
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"ID3v2.3 extended header", extended(3)},
+		{"ID3v2.4 extended header", extended(4)},
+		{"ID3v2.4 footer", footer},
+	}
+
+	for _, test := range tests {
+		path := writeFile(t, append(test.data, audio...))
+
+		// A small cover fits in the padding, a large one needs a rewrite
+		for _, cover := range [][]byte{[]byte("jpeg data"), bytes.Repeat([]byte("jpeg data"), 64)} {
+			if err := id3art.Embed(path, &artwork.Image{Data: cover, Format: "jpeg"}, true); err != nil {
+				t.Fatalf("%s: unexpected error %v", test.name, err)
+			}
+
+			file, _ := ioutil.ReadFile(path)
+			if file[5] != 0 || !bytes.HasSuffix(file, audio) || bytes.Contains(file, []byte("3DI")) {
+				t.Errorf("%s: expected the flags to be cleared and the audio data preserved", test.name)
+			}
+
+			data, _, err := id3art.ReadCover(path)
+			if !bytes.Equal(data, cover) || err != nil {
+				t.Errorf("%s: unexpected cover %q %v", test.name, data, err)
+			}
+
+			if tags, err := id3art.ReadTags(path); err != nil || tags.Title != "Halcyon Days" {
+				t.Errorf("%s: unexpected tags %+v %v", test.name, tags, err)
+			}
+		}
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	tag := id3Tag(4, 0)
+	tag[3] = 2
+	path := writeFile(t, append(tag, audio...))
+
+	if _, err := id3art.HasCover(path); !errors.Is(err, id3art.ErrUnsupportedVersion) {
+		t.Errorf("expected an unsupported version error, got %v", err)
+	}
+}
+
+func TestReadTags(t *testing.T) {
+	// A UTF-16 album frame, with a byte order mark
+	album := []byte{1, 0xff, 0xfe, 'H', 0, 'a', 0, 'l', 0, 'c', 0, 'y', 0, 'o', 0, 'n', 0}
+	frame := append([]byte{'T', 'A', 'L', 'B', 0, 0, 0, byte(len(album)), 0, 0}, album...)
+
+	tag := id3Tag(3, 0)
+	tag = append(append(tag[:10:10], frame...), tag[10:]...)
+	tag[9] += byte(len(frame))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected artwork.Tags
+	}{
+		{"no tag", audio, artwork.Tags{}},
+		{"ID3v2.3", append(tag, audio...), artwork.Tags{Album: "Halcyon", Title: "Halcyon Days"}},
+		{"ID3v2.4", append(id3Tag(4, 16), audio...), artwork.Tags{Title: "Halcyon Days"}},
+	}
+
+	for _, test := range tests {
+		tags, err := id3art.ReadTags(writeFile(t, test.data))
+		if err != nil || *tags != test.expected {
+			t.Errorf("%s: unexpected tags %+v %v", test.name, tags, err)
+		}
+	}
+}
+
+func ExampleEmbed() {
+	result, err := coverart.Itunes().Album(context.Background(), "halcyon days", "ellie goulding")
+	if err != nil {
+		return
+	}
+
+	img, err := coverart.Fetch(context.Background(), result)
+	if err != nil {
+		return
+	}
+
+	if err := id3art.Embed("track.mp3", img, true); err != nil {
+		fmt.Println("error", err)
+	}
+}
@@ -1,18 +1,104 @@
 // Package itunesart provides few helper methods to get album, artist or track
-// artworks from the Itunes API
+// artworks from the Itunes API, as well as the artworks of the other entities
+// of the API like podcasts, audiobooks and music videos
 package itunesart
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// DefaultBaseURL is the base url of the Itunes Search API
+const DefaultBaseURL = "https://itunes.apple.com"
+
+const apiUrlTrack = "/search?" + string(TrackEntity) + "&term="
+const apiUrlAlbum = "/search?" + string(AlbumEntity) + "&term="
+const apiUrlArtist = "/search?media=music&entity=musicArtist&term="
+const apiUrlArtistAlbums = "/lookup?entity=album&limit=5&id="
+
+// The Entity represents the kind of items searched with Client.Cover and
+// Client.Candidates
+type Entity string
+
+// Entities of the Itunes Search API which have artworks
+const (
+	AlbumEntity      Entity = "media=music&entity=album"
+	TrackEntity      Entity = "media=music&entity=musicTrack"
+	MusicVideoEntity Entity = "media=musicVideo&entity=musicVideo"
+	PodcastEntity    Entity = "media=podcast&entity=podcast"
+	AudiobookEntity  Entity = "media=audiobook&entity=audiobook"
+)
+
+// Number of candidates requested by the candidate searches, by default and at
+// most
+const (
+	DefaultLimit = 10
+	MaxLimit     = 200
 )
 
-const apiUrlTrack = "https://itunes.apple.com/search?media=music&entity=musicTrack&limit=1&term="
-const apiUrlAlbum = "https://itunes.apple.com/search?media=music&entity=album&limit=1&term="
+// MaxArtworkSize is the largest size of the artworks served by the Itunes
+// image servers
+const MaxArtworkSize = 3000
+
+// Matches the size segment of the artwork urls, like 100x100bb.jpg
+var sizeSegment = regexp.MustCompile(`/(\d+)x(\d+)[a-z]*(-\d+)?\.(jpg|jpeg|png)$`)
+
+// The Client represents an Itunes Search API client. HTTPClient and BaseURL
+// can be changed to use a custom transport, proxy or mirror of the API. The
+// fields must be set before the Client is used, they are read without
+// synchronization by the requests.
+type Client struct {
+	// HTTPClient is used to execute the requests, http.DefaultClient is
+	// used when nil
+	HTTPClient *http.Client
+
+	// Retry is the policy applied to the requests failing with a transient
+	// error, like a rate limit or a server error
+	Retry artwork.RetryPolicy
+
+	// BaseURL is the url the API paths are appended to
+	BaseURL string
+
+	// Country is the two letter code of the store searched, like jp or de.
+	// The API searches the US store when it is empty.
+	Country string
+
+	// Lang is the language of the results, like en_us or ja_jp. The API
+	// answers in English when it is empty.
+	Lang string
+
+	// Storefronts are the country codes of the stores searched in order when
+	// the store of the Country has no match
+	Storefronts []string
+
+	// ArtworkSize is the size of the Large artwork of the cover lookups, up
+	// to MaxArtworkSize. The url of the 100 pixels artwork is rewritten to
+	// this size, see ResizeURL. The rewritten artwork is only used when the
+	// image servers have it, and when it is larger than the artworks of the
+	// API, like the 600 pixels artworks of the podcasts.
+	ArtworkSize int
+}
+
+// defaultClient is used by the package level helper methods
+var defaultClient = NewClient()
+
+// NewClient returns a new Client using the default http client and base url
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{},
+		Retry:      artwork.DefaultRetryPolicy,
+		BaseURL:    DefaultBaseURL,
+	}
+}
 
 // The Result represents the specific size of artworks and contains the url of
 // each size of artwork returned by the Itunes API
@@ -20,32 +106,91 @@ type Result struct {
 	Tiny    string
 	Small   string
 	Medium  string
+	Large   string
 	Default string
 }
 
-type httpResult struct {
+// The Candidate represents one of the matches of a search, with its metadata
+// and artworks
+type Candidate struct {
+	// ID is the collection id of the albums, the track id of the tracks
+	ID          int64
+	Name        string
+	Artist      string
+	Album       string
+	ReleaseDate string
+	Genre       string
+	Country     string
+	TrackCount  int
+	Result      Result
+}
+
+// Artwork converts the result to the Artwork shared by the service packages.
+// The images of the Itunes API are 30, 60 and 100 pixels wide.
+func (r Result) Artwork() artwork.Artwork {
+	a := artwork.Artwork{Provider: artwork.ItunesName, Retrieved: time.Now()}
+	a.Add(artwork.Source{URL: r.Tiny, Width: 30, Height: 30})
+	a.Add(artwork.Source{URL: r.Small, Width: 60, Height: 60})
+	a.Add(artwork.Source{URL: r.Medium, Width: 100, Height: 100})
+
+	size := SizeOf(r.Large)
+	a.Add(artwork.Source{URL: r.Large, Width: size, Height: size})
+
+	size = SizeOf(r.Default)
+	a.Add(artwork.Source{URL: r.Default, Width: size, Height: size})
+
+	return a
+}
+
+// Artwork converts the candidate to the Artwork shared by the service
+// packages, with the id, name and artist of the match
+func (c Candidate) Artwork() artwork.Artwork {
+	a := c.Result.Artwork()
+	a.ID = strconv.FormatInt(c.ID, 10)
+	a.Name, a.Artist = c.Name, c.Artist
+
+	return a
+}
+
+type httpArtwork struct {
 	Tiny    string `json:"artworkUrl30"`
 	Small   string `json:"artworkUrl60"`
 	Medium  string `json:"artworkUrl100"`
+	Large   string `json:"artworkUrl600"`
 	Default string
 }
 
+type httpResult struct {
+	httpArtwork
+	CollectionId   int64  `json:"collectionId"`
+	TrackId        int64  `json:"trackId"`
+	ArtistId       int64  `json:"artistId"`
+	ArtistName     string `json:"artistName"`
+	CollectionName string `json:"collectionName"`
+	TrackName      string `json:"trackName"`
+	ReleaseDate    string `json:"releaseDate"`
+	Genre          string `json:"primaryGenreName"`
+	Country        string `json:"country"`
+	TrackCount     int    `json:"trackCount"`
+}
+
 type httpResponse struct {
-	ResultCount int          `json:resultCount`
-	Results     []httpResult `json:results`
+	ResultCount int          `json:"resultCount"`
+	Results     []httpResult `json:"results"`
 }
 
 // Build all the artworks into size typed object for easy access
 // { Result.SizeName }
 // e.g: Result.Small would return the url for a small size artwork
-func buildResult(result httpResult) (Result, error) {
+func buildResult(result httpArtwork) (Result, error) {
 	v := reflect.ValueOf(result)
 	min := false
 	res := Result{
-		result.Tiny,
-		result.Small,
-		result.Medium,
-		result.Default,
+		Tiny:    result.Tiny,
+		Small:   result.Small,
+		Medium:  result.Medium,
+		Large:   result.Large,
+		Default: result.Default,
 	}
 
 	for i := 0; i < v.NumField(); i++ {
@@ -58,7 +203,7 @@ func buildResult(result httpResult) (Result, error) {
 	}
 
 	if !min {
-		return res, errors.New("No artwork was found")
+		return res, artwork.NotFound("No artwork was found")
 	}
 
 	return res, nil
@@ -74,16 +219,242 @@ func parseResults(data []byte) (Result, error) {
 	}
 
 	if resp.ResultCount == 0 {
-		return Result{}, errors.New("No match was found")
+		return Result{}, artwork.NotFound("No match was found")
+	}
+
+	return buildResult(resp.Results[0].httpArtwork)
+}
+
+// Parse http response and build the candidates which have an artwork, in the
+// order of the response
+func parseCandidates(data []byte) ([]Candidate, error) {
+	resp := httpResponse{}
+
+	err := json.Unmarshal(data, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []Candidate{}
+	for _, r := range resp.Results {
+		res, err := buildResult(r.httpArtwork)
+		if err != nil {
+			continue
+		}
+
+		c := Candidate{
+			ID:          r.CollectionId,
+			Name:        r.CollectionName,
+			Artist:      r.ArtistName,
+			Album:       r.CollectionName,
+			ReleaseDate: r.ReleaseDate,
+			Genre:       r.Genre,
+			Country:     r.Country,
+			TrackCount:  r.TrackCount,
+			Result:      res,
+		}
+
+		if r.TrackId != 0 {
+			c.ID, c.Name = r.TrackId, r.TrackName
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	if len(candidates) == 0 {
+		return nil, artwork.NotFound("No match was found")
+	}
+
+	return candidates, nil
+}
+
+// Used to build the url of a search in the store of the country, the limit is
+// bounded to MaxLimit
+func (c *Client) searchUrl(apiUrl string, term string, limit int, country string) string {
+	switch {
+	case limit <= 0:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+
+	return c.BaseURL + apiUrl + url.QueryEscape(term) + "&limit=" + strconv.Itoa(limit) + c.storeParams(country)
+}
+
+// Used to build the parameters selecting the store of the country and the
+// language of the client
+func (c *Client) storeParams(country string) string {
+	params := ""
+	if len(country) > 0 {
+		params += "&country=" + url.QueryEscape(country)
+	}
+
+	if len(c.Lang) > 0 {
+		params += "&lang=" + url.QueryEscape(c.Lang)
+	}
+
+	return params
+}
+
+// Used to build the search path of the entity
+func entityUrl(entity Entity) string {
+	return "/search?" + string(entity) + "&term="
+}
+
+// Used to run a search in the store of the Country, then in the Storefronts
+// in order until one of them has a match. The response of the last store is
+// returned when none has, with the country of the store.
+func (c *Client) search(ctx context.Context, apiUrl string, term string, limit int) ([]byte, string, error) {
+	var data []byte
+	var country string
+
+	for _, country = range append([]string{c.Country}, c.Storefronts...) {
+		var err error
+		data, err = c.request(ctx, c.searchUrl(apiUrl, term, limit, country))
+		if err != nil {
+			return nil, "", err
+		}
+
+		resp := httpResponse{}
+		if json.Unmarshal(data, &resp) != nil || resp.ResultCount > 0 {
+			break
+		}
+	}
+
+	return data, country, nil
+}
+
+// ResizeURL rewrites the size segment of an artwork url of the Itunes image
+// servers, like 100x100bb.jpg, to get the artwork in another size. The size is
+// bounded to MaxArtworkSize. The url is returned as is when it has no size
+// segment.
+func ResizeURL(url string, size int) string {
+	size = min(size, MaxArtworkSize)
+	if size <= 0 || !sizeSegment.MatchString(url) {
+		return url
+	}
+
+	return sizeSegment.ReplaceAllString(url, "/"+strconv.Itoa(size)+"x"+strconv.Itoa(size)+"bb.$4")
+}
+
+// SizeOf returns the width of the artwork from the size segment of its url,
+// zero when the url has none
+func SizeOf(url string) int {
+	m := sizeSegment.FindStringSubmatch(url)
+	if m == nil {
+		return 0
+	}
+
+	size, _ := strconv.Atoi(m[1])
+	return size
+}
+
+// Used to rewrite the Medium artwork of the result to the ArtworkSize of the
+// client as its Large artwork, when it is larger than the artworks of the API.
+// The rewritten artwork is not checked.
+func (c *Client) rewrite(res Result) Result {
+	if c.ArtworkSize <= max(SizeOf(res.Medium), SizeOf(res.Large)) {
+		return res
+	}
+
+	if large := ResizeURL(res.Medium, c.ArtworkSize); large != res.Medium {
+		res.Large = large
+	}
+
+	return res
+}
+
+// Used to rewrite and check the Large artwork of the result of a cover lookup
+func (c *Client) upscale(ctx context.Context, res Result) Result {
+	return c.VerifyContext(ctx, c.rewrite(res))
+}
+
+// Used to rewrite the Large artwork of the candidates, see Client.Verify
+func (c *Client) rewriteAll(candidates []Candidate, err error) ([]Candidate, error) {
+	for i := range candidates {
+		candidates[i].Result = c.rewrite(candidates[i].Result)
+	}
+
+	return candidates, err
+}
+
+// Verify checks that the image servers have the Large artwork of the result,
+// which is not checked for the candidates to avoid a request per candidate.
+// The Default artwork becomes the Large one when they have it, the rewritten
+// Large artwork is dropped otherwise.
+func (c *Client) Verify(res Result) Result {
+	return c.VerifyContext(context.Background(), res)
+}
+
+// VerifyContext is like Verify, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) VerifyContext(ctx context.Context, res Result) Result {
+	if len(res.Large) == 0 || res.Large == res.Default {
+		return res
+	}
+
+	if c.available(ctx, res.Large) {
+		res.Default = res.Large
+		return res
+	}
+
+	// The Default artwork is the largest artwork of the API, like the 600
+	// pixels artworks of the podcasts
+	res.Large = ""
+	if SizeOf(res.Default) > SizeOf(res.Medium) {
+		res.Large = res.Default
+	}
+
+	return res
+}
+
+// Used to check that the image servers have the artwork, with a HEAD request
+func (c *Client) available(ctx context.Context, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return false
 	}
 
-	return buildResult(resp.Results[0])
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return false
+	}
+
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Used to get the http client of the client
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
+}
+
+// Executes an http request, retried according to the client policy, and
+// returns error or response body
+func (c *Client) request(ctx context.Context, url string) ([]byte, error) {
+	var body []byte
+
+	err := c.Retry.Do(ctx, func() error {
+		var err error
+		body, err = c.requestOnce(ctx, url)
+		return err
+	})
+
+	return body, err
 }
 
-// Executes an http request and returns error or response body
-func request(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+// Executes a single http request and returns error or response body
+func (c *Client) requestOnce(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -95,31 +466,291 @@ func request(url string) ([]byte, error) {
 		return nil, err
 	}
 
-	return body, err
+	// The Itunes API answers with a 403 status once the rate limit is reached
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, &artwork.RateLimitError{
+			RetryAfter: artwork.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			Message:    resp.Status,
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, artwork.ResponseError(resp, "")
+	}
+
+	return body, nil
 }
 
 // AlbumCover gets the album artworks art from the Itunes database through out it's
 // dedicated API.
-func AlbumCover(album string, artist string) (Result, error) {
-	url := apiUrlAlbum + url.QueryEscape(album+" "+artist)
+func (c *Client) AlbumCover(album string, artist string) (Result, error) {
+	return c.AlbumCoverContext(context.Background(), album, artist)
+}
+
+// AlbumCoverContext is like AlbumCover, but the request is bound to ctx so it
+// can be cancelled or given a deadline.
+func (c *Client) AlbumCoverContext(ctx context.Context, album string, artist string) (Result, error) {
+	data, _, err := c.search(ctx, apiUrlAlbum, album+" "+artist, 1)
+	if err != nil {
+		return Result{}, err
+	}
 
-	data, err := request(url)
+	res, err := parseResults(data)
 	if err != nil {
 		return Result{}, err
 	}
 
-	return parseResults(data)
+	return c.upscale(ctx, res), nil
 }
 
 // TrackCover gets the track artworks from the Itunes database through out it's
 // dedicated API.
-func TrackCover(track string, artist string) (Result, error) {
-	url := apiUrlTrack + url.QueryEscape(track+" "+artist)
+func (c *Client) TrackCover(track string, artist string) (Result, error) {
+	return c.TrackCoverContext(context.Background(), track, artist)
+}
+
+// TrackCoverContext is like TrackCover, but the request is bound to ctx so it
+// can be cancelled or given a deadline.
+func (c *Client) TrackCoverContext(ctx context.Context, track string, artist string) (Result, error) {
+	data, _, err := c.search(ctx, apiUrlTrack, track+" "+artist, 1)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res, err := parseResults(data)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return c.upscale(ctx, res), nil
+}
+
+// ArtistCover gets the artist artwork from the Itunes database through out it's
+// dedicated API. The artists rarely have an artwork of their own, the artwork
+// of one of their albums is returned instead.
+func (c *Client) ArtistCover(artist string) (Result, error) {
+	return c.ArtistCoverContext(context.Background(), artist)
+}
 
-	data, err := request(url)
+// ArtistCoverContext is like ArtistCover, but the requests are bound to ctx so
+// they can be cancelled or given a deadline.
+func (c *Client) ArtistCoverContext(ctx context.Context, artist string) (Result, error) {
+	data, country, err := c.search(ctx, apiUrlArtist, artist, 1)
 	if err != nil {
 		return Result{}, err
 	}
 
-	return parseResults(data)
+	resp := httpResponse{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return Result{}, err
+	}
+
+	if resp.ResultCount == 0 || len(resp.Results) == 0 {
+		return Result{}, artwork.NotFound("No match was found")
+	}
+
+	found := resp.Results[0]
+	if res, err := buildResult(found.httpArtwork); err == nil {
+		return c.upscale(ctx, res), nil
+	}
+
+	// The lookup lists the artist first, then its albums, in the store the
+	// artist was found in
+	Url := c.BaseURL + apiUrlArtistAlbums + strconv.FormatInt(found.ArtistId, 10) + c.storeParams(country)
+	data, err = c.request(ctx, Url)
+	if err != nil {
+		return Result{}, err
+	}
+
+	candidates, err := parseCandidates(data)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return c.upscale(ctx, candidates[0].Result), nil
+}
+
+// Cover gets the artwork of the first item of the entity matching the term,
+// like a podcast, an audiobook or a music video, from the Itunes database.
+func (c *Client) Cover(entity Entity, term string) (Result, error) {
+	return c.CoverContext(context.Background(), entity, term)
+}
+
+// CoverContext is like Cover, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) CoverContext(ctx context.Context, entity Entity, term string) (Result, error) {
+	data, _, err := c.search(ctx, entityUrl(entity), term, 1)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res, err := parseResults(data)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return c.upscale(ctx, res), nil
+}
+
+// Candidates gets up to limit items of the entity matching the term from the
+// Itunes database, with their metadata and artworks, in the order of relevance
+// of the API. DefaultLimit candidates are requested when limit is zero. The
+// Large artworks rewritten to the ArtworkSize are not checked, see
+// Client.Verify.
+func (c *Client) Candidates(entity Entity, term string, limit int) ([]Candidate, error) {
+	return c.CandidatesContext(context.Background(), entity, term, limit)
+}
+
+// CandidatesContext is like Candidates, but the request is bound to ctx so it
+// can be cancelled or given a deadline.
+func (c *Client) CandidatesContext(ctx context.Context, entity Entity, term string, limit int) ([]Candidate, error) {
+	data, _, err := c.search(ctx, entityUrl(entity), term, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.rewriteAll(parseCandidates(data))
+}
+
+// AlbumCandidates gets up to limit albums matching the query from the Itunes
+// database, with their metadata and artworks, in the order of relevance of
+// the API. DefaultLimit candidates are requested when limit is zero. The Large
+// artworks rewritten to the ArtworkSize are not checked, see Client.Verify.
+func (c *Client) AlbumCandidates(album string, artist string, limit int) ([]Candidate, error) {
+	return c.AlbumCandidatesContext(context.Background(), album, artist, limit)
+}
+
+// AlbumCandidatesContext is like AlbumCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) AlbumCandidatesContext(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	data, _, err := c.search(ctx, apiUrlAlbum, album+" "+artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.rewriteAll(parseCandidates(data))
+}
+
+// TrackCandidates gets up to limit tracks matching the query from the Itunes
+// database, with their metadata and artworks, in the order of relevance of
+// the API. DefaultLimit candidates are requested when limit is zero. The Large
+// artworks rewritten to the ArtworkSize are not checked, see Client.Verify.
+func (c *Client) TrackCandidates(track string, artist string, limit int) ([]Candidate, error) {
+	return c.TrackCandidatesContext(context.Background(), track, artist, limit)
+}
+
+// TrackCandidatesContext is like TrackCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) TrackCandidatesContext(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	data, _, err := c.search(ctx, apiUrlTrack, track+" "+artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.rewriteAll(parseCandidates(data))
+}
+
+// AlbumCover gets the album artwork with the default client
+func AlbumCover(album string, artist string) (Result, error) {
+	return defaultClient.AlbumCover(album, artist)
+}
+
+// AlbumCoverContext gets the album artwork with the default client, the request
+// is bound to ctx
+func AlbumCoverContext(ctx context.Context, album string, artist string) (Result, error) {
+	return defaultClient.AlbumCoverContext(ctx, album, artist)
+}
+
+// TrackCover gets the track artwork with the default client
+func TrackCover(track string, artist string) (Result, error) {
+	return defaultClient.TrackCover(track, artist)
+}
+
+// TrackCoverContext gets the track artwork with the default client, the request
+// is bound to ctx
+func TrackCoverContext(ctx context.Context, track string, artist string) (Result, error) {
+	return defaultClient.TrackCoverContext(ctx, track, artist)
+}
+
+// ArtistCover gets the artist artwork with the default client
+func ArtistCover(artist string) (Result, error) {
+	return defaultClient.ArtistCover(artist)
+}
+
+// ArtistCoverContext gets the artist artwork with the default client, the
+// requests are bound to ctx
+func ArtistCoverContext(ctx context.Context, artist string) (Result, error) {
+	return defaultClient.ArtistCoverContext(ctx, artist)
+}
+
+// Cover gets the artwork of the entity with the default client
+func Cover(entity Entity, term string) (Result, error) {
+	return defaultClient.Cover(entity, term)
+}
+
+// CoverContext gets the artwork of the entity with the default client, the
+// request is bound to ctx
+func CoverContext(ctx context.Context, entity Entity, term string) (Result, error) {
+	return defaultClient.CoverContext(ctx, entity, term)
+}
+
+// Candidates gets the candidates of the entity with the default client
+func Candidates(entity Entity, term string, limit int) ([]Candidate, error) {
+	return defaultClient.Candidates(entity, term, limit)
+}
+
+// CandidatesContext gets the candidates of the entity with the default
+// client, the request is bound to ctx
+func CandidatesContext(ctx context.Context, entity Entity, term string, limit int) ([]Candidate, error) {
+	return defaultClient.CandidatesContext(ctx, entity, term, limit)
+}
+
+// AlbumCandidates gets the album candidates with the default client
+func AlbumCandidates(album string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.AlbumCandidates(album, artist, limit)
+}
+
+// AlbumCandidatesContext gets the album candidates with the default client,
+// the request is bound to ctx
+func AlbumCandidatesContext(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.AlbumCandidatesContext(ctx, album, artist, limit)
+}
+
+// TrackCandidates gets the track candidates with the default client
+func TrackCandidates(track string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.TrackCandidates(track, artist, limit)
+}
+
+// TrackCandidatesContext gets the track candidates with the default client,
+// the request is bound to ctx
+func TrackCandidatesContext(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.TrackCandidatesContext(ctx, track, artist, limit)
+}
+
+// Verify checks the Large artwork of the result with the default client
+func Verify(res Result) Result {
+	return defaultClient.Verify(res)
+}
+
+// VerifyContext checks the Large artwork of the result with the default
+// client, the request is bound to ctx
+func VerifyContext(ctx context.Context, res Result) Result {
+	return defaultClient.VerifyContext(ctx, res)
+}
+
+// SetArtworkSize sets the size of the Large artwork of the default client, see
+// Client.ArtworkSize. It must be called before any lookup, usually at init, as
+// the lookups in progress read the setting; use a Client of its own to change
+// it afterwards.
+func SetArtworkSize(size int) {
+	defaultClient.ArtworkSize = size
+}
+
+// SetStorefront sets the store searched by the default client, its language
+// and the stores searched when it has no match, see Client.Country. Like
+// SetArtworkSize, it must be called before any lookup.
+func SetStorefront(country string, lang string, storefronts ...string) {
+	defaultClient.Country = country
+	defaultClient.Lang = lang
+	defaultClient.Storefronts = storefronts
 }
@@ -1,8 +1,14 @@
 package itunesart_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/piraveen/go-coverart/artwork"
 	"github.com/piraveen/go-coverart/itunesart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -22,6 +28,305 @@ func TestTrackCover(t *testing.T) {
 	}
 }
 
+func TestAlbumCoverContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := itunesart.AlbumCoverContext(ctx, "unapologetic", "rihanna")
+	if err == nil {
+		t.Errorf("expected an error for a cancelled context")
+	}
+}
+
+func TestClientBaseURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("entity") != "album" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		fmt.Fprint(w, `{"resultCount": 1, "results": [{"artworkUrl30": "30.jpg", "artworkUrl60": "60.jpg", "artworkUrl100": "100.jpg"}]}`)
+	}))
+	defer ts.Close()
+
+	client := itunesart.NewClient()
+	client.HTTPClient = ts.Client()
+	client.BaseURL = ts.URL
+
+	results, err := client.AlbumCover("unapologetic", "rihanna")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if results.Tiny != "30.jpg" || results.Default != "100.jpg" {
+		t.Errorf("unexpected result %+v", results)
+	}
+}
+
+func TestClientCandidates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "3" || r.URL.Query().Get("entity") != "musicTrack" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		fmt.Fprint(w, `{"resultCount": 3, "results": [
+			{"trackId": 1, "trackName": "Stay", "collectionId": 10, "collectionName": "Unapologetic", "artistName": "Rihanna", "releaseDate": "2012-11-19T08:00:00Z", "trackCount": 14, "artworkUrl100": "1.jpg"},
+			{"trackId": 2, "trackName": "Stay (Live)"},
+			{"trackId": 3, "trackName": "Stay", "collectionName": "Unapologetic (Deluxe)", "artistName": "Rihanna", "artworkUrl100": "3.jpg"}
+		]}`)
+	}))
+	defer ts.Close()
+
+	client := itunesart.NewClient()
+	client.BaseURL = ts.URL
+
+	candidates, err := client.TrackCandidates("stay", "rihanna", 3)
+	if err != nil || len(candidates) != 2 {
+		t.Fatalf("unexpected candidates %+v %v", candidates, err)
+	}
+
+	first := candidates[0]
+	if first.ID != 1 || first.Name != "Stay" || first.Album != "Unapologetic" || first.ReleaseDate != "2012-11-19T08:00:00Z" || first.TrackCount != 14 || first.Result.Medium != "1.jpg" {
+		t.Errorf("unexpected candidate %+v", first)
+	}
+
+	if candidates[1].Album != "Unapologetic (Deluxe)" {
+		t.Errorf("unexpected candidate %+v", candidates[1])
+	}
+
+	a := first.Artwork()
+	if src, ok := a.Largest(); a.Provider != "itunes" || a.ID != "1" || a.Artist != "Rihanna" || len(a.Images) != 1 || !ok || src.Width != 100 || src.Format != "jpeg" {
+		t.Errorf("unexpected artwork %+v", a)
+	}
+}
+
+func TestResizeURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		size     int
+		expected string
+	}{
+		{"https://is1-ssl.mzstatic.com/image/thumb/Music/v4/a/b/source/100x100bb.jpg", 600, "https://is1-ssl.mzstatic.com/image/thumb/Music/v4/a/b/source/600x600bb.jpg"},
+		{"https://is1-ssl.mzstatic.com/image/thumb/Music/a/source/60x60bb-85.png", 5000, "https://is1-ssl.mzstatic.com/image/thumb/Music/a/source/3000x3000bb.png"},
+		{"https://is1-ssl.mzstatic.com/image/thumb/Music/a/source/100x100bb.jpg", 0, "https://is1-ssl.mzstatic.com/image/thumb/Music/a/source/100x100bb.jpg"},
+		{"https://example.com/cover.jpg", 600, "https://example.com/cover.jpg"},
+	}
+
+	for _, test := range tests {
+		if u := itunesart.ResizeURL(test.url, test.size); u != test.expected {
+			t.Errorf("%s %d: expected %s, got %s", test.url, test.size, test.expected, u)
+		}
+	}
+
+	if size := itunesart.SizeOf("https://is1-ssl.mzstatic.com/a/source/600x600bb.jpg"); size != 600 {
+		t.Errorf("unexpected size %d", size)
+	}
+}
+
+func TestClientArtworkSize(t *testing.T) {
+	heads := 0
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			heads++
+		}
+
+		switch r.URL.Path {
+		case "/search":
+			fmt.Fprintf(w, `{"resultCount": 1, "results": [{"artworkUrl100": "%s/image/100x100bb.jpg"}]}`, ts.URL)
+		case "/image/1200x1200bb.jpg":
+			if r.Method != "HEAD" {
+				t.Errorf("unexpected method %s", r.Method)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client := itunesart.NewClient()
+	client.BaseURL = ts.URL
+	client.ArtworkSize = 1200
+
+	res, err := client.AlbumCover("unapologetic", "rihanna")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if res.Large != ts.URL+"/image/1200x1200bb.jpg" || res.Default != res.Large || res.Medium != ts.URL+"/image/100x100bb.jpg" {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	if src, ok := res.Artwork().Largest(); !ok || src.Width != 1200 {
+		t.Errorf("unexpected largest image %+v", src)
+	}
+
+	// The 100 pixels artwork is kept when the larger one is missing
+	client.ArtworkSize = 3000
+	res, err = client.AlbumCover("unapologetic", "rihanna")
+	if err != nil || len(res.Large) > 0 || res.Default != ts.URL+"/image/100x100bb.jpg" {
+		t.Errorf("unexpected result %+v %v", res, err)
+	}
+
+	if src, ok := res.Artwork().Largest(); !ok || src.Width != 100 {
+		t.Errorf("unexpected largest image %+v", src)
+	}
+
+	// The artworks of the candidates are only checked by Verify
+	heads = 0
+	client.ArtworkSize = 1200
+	candidates, err := client.AlbumCandidates("unapologetic", "rihanna", 0)
+	if err != nil || len(candidates) != 1 || candidates[0].Result.Large != ts.URL+"/image/1200x1200bb.jpg" || heads != 0 {
+		t.Fatalf("unexpected candidates %+v %v, %d requests", candidates, err, heads)
+	}
+
+	res = client.Verify(candidates[0].Result)
+	if res.Default != res.Large || res.Large != ts.URL+"/image/1200x1200bb.jpg" || heads != 1 {
+		t.Errorf("unexpected result %+v, %d requests", res, heads)
+	}
+
+	client.ArtworkSize = 3000
+	candidates, err = client.AlbumCandidates("unapologetic", "rihanna", 0)
+	if err != nil || len(candidates) != 1 {
+		t.Fatalf("unexpected candidates %+v %v", candidates, err)
+	}
+
+	res = client.Verify(candidates[0].Result)
+	if len(res.Large) > 0 || res.Default != ts.URL+"/image/100x100bb.jpg" {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestClientStorefronts(t *testing.T) {
+	countries := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("lang") != "ja_jp" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		countries = append(countries, q.Get("country"))
+		if q.Get("country") != "jp" {
+			fmt.Fprint(w, `{"resultCount": 0, "results": []}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"resultCount": 1, "results": [{"collectionName": "Fantôme", "country": "JPN", "artworkUrl100": "100.jpg"}]}`)
+	}))
+	defer ts.Close()
+
+	client := itunesart.NewClient()
+	client.BaseURL = ts.URL
+	client.Lang = "ja_jp"
+	client.Storefronts = []string{"de", "jp", "fr"}
+
+	candidates, err := client.AlbumCandidates("fantôme", "hikaru utada", 5)
+	if err != nil || len(candidates) != 1 || candidates[0].Country != "JPN" {
+		t.Fatalf("unexpected candidates %+v %v", candidates, err)
+	}
+
+	if strings.Join(countries, ",") != ",de,jp" {
+		t.Errorf("unexpected stores searched %q", countries)
+	}
+
+	client.Country, client.Storefronts = "us", nil
+	if _, err := client.AlbumCover("fantôme", "hikaru utada"); !errors.Is(err, artwork.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestClientArtistCover(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/search" && q.Get("entity") == "musicArtist" && q.Get("country") != "jp":
+			fmt.Fprint(w, `{"resultCount": 0, "results": []}`)
+		case r.URL.Path == "/search" && q.Get("entity") == "musicArtist":
+			fmt.Fprint(w, `{"resultCount": 1, "results": [{"wrapperType": "artist", "artistId": 63346553, "artistName": "Rihanna"}]}`)
+		case r.URL.Path == "/lookup" && q.Get("id") == "63346553" && q.Get("entity") == "album" && q.Get("country") == "jp":
+			fmt.Fprint(w, `{"resultCount": 2, "results": [
+				{"wrapperType": "artist", "artistId": 63346553, "artistName": "Rihanna"},
+				{"wrapperType": "collection", "collectionName": "Anti", "artworkUrl60": "60.jpg", "artworkUrl100": "100.jpg"}
+			]}`)
+		default:
+			t.Errorf("unexpected request %v", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client := itunesart.NewClient()
+	client.BaseURL = ts.URL
+	client.Storefronts = []string{"jp"}
+
+	// The albums are looked up in the store the artist was found in
+	res, err := client.ArtistCover("rihanna")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if res.Small != "60.jpg" || res.Default != "100.jpg" {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestClientEntities(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("media") != "podcast" || q.Get("entity") != "podcast" || q.Get("term") != "the daily" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		fmt.Fprint(w, `{"resultCount": 2, "results": [
+			{"trackId": 1200361736, "trackName": "The Daily", "collectionName": "The Daily", "artistName": "The New York Times", "artworkUrl100": "100.jpg", "artworkUrl600": "600.jpg"},
+			{"trackId": 2, "trackName": "The Daily Show", "artistName": "Comedy Central", "artworkUrl100": "2.jpg"}
+		]}`)
+	}))
+	defer ts.Close()
+
+	client := itunesart.NewClient()
+	client.BaseURL = ts.URL
+
+	res, err := client.Cover(itunesart.PodcastEntity, "the daily")
+	if err != nil || res.Medium != "100.jpg" || res.Large != "600.jpg" || res.Default != "600.jpg" {
+		t.Errorf("unexpected result %+v %v", res, err)
+	}
+
+	candidates, err := client.Candidates(itunesart.PodcastEntity, "the daily", 2)
+	if err != nil || len(candidates) != 2 || candidates[0].ID != 1200361736 || candidates[0].Artist != "The New York Times" {
+		t.Errorf("unexpected candidates %+v %v", candidates, err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		expected error
+	}{
+		{http.StatusOK, `{"resultCount": 0, "results": []}`, artwork.ErrNotFound},
+		{http.StatusOK, `{"resultCount": 1, "results": [{}]}`, artwork.ErrNotFound},
+		{http.StatusForbidden, ``, artwork.ErrRateLimited},
+		{http.StatusServiceUnavailable, ``, artwork.ErrUpstream},
+	}
+
+	for _, test := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			fmt.Fprint(w, test.body)
+		}))
+
+		client := itunesart.NewClient()
+		client.BaseURL = ts.URL
+		client.Retry = artwork.NoRetry
+
+		if _, err := client.TrackCover("stay", "rihanna"); !errors.Is(err, test.expected) {
+			t.Errorf("%d %s: expected %v, got %v", test.status, test.body, test.expected, err)
+		}
+
+		ts.Close()
+	}
+}
+
 func ExampleAlbumCover() {
 	results, err := itunesart.AlbumCover("unapologetic", "rihanna")
 	if err == nil {
@@ -39,3 +344,17 @@ func ExampleTrackCover() {
 		// TrackCover http://is4.mzstatic.com/image/thumb/Music/v4/7a/d3/8d/7ad38df1-c8da-f278-af55-e346a073451a/source/100x100bb.jpg
 	}
 }
+
+func ExampleArtistCover() {
+	results, err := itunesart.ArtistCover("rihanna")
+	if err == nil {
+		fmt.Printf("ArtistCover %v\n", results.Default)
+	}
+}
+
+func ExampleCover() {
+	results, err := itunesart.Cover(itunesart.PodcastEntity, "the daily")
+	if err == nil {
+		fmt.Printf("PodcastCover %v\n", results.Default)
+	}
+}
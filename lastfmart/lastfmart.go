@@ -3,20 +3,69 @@
 package lastfmart
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piraveen/go-coverart/artwork"
 )
 
-var apiKey string
-var apiCorrect bool
+// DefaultBaseURL is the base url of the Last.fm API
+const DefaultBaseURL = "http://ws.audioscrobbler.com/2.0/"
 
-const apiUrl = "http://ws.audioscrobbler.com/2.0/?format=json&method="
+const apiUrl = "?format=json&method="
 const checkApiUrl = apiUrl + "user.getinfo&user=rj&api_key="
 
+// Number of candidates requested by the candidate searches, by default and at
+// most
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+)
+
+// The Client represents a Last.fm API client. Each Client owns its API Key,
+// settings and http client, so several independently configured clients can
+// be used in the same process. A Client is safe for concurrent use: the API
+// Key and the autocorrect setting can be changed at any time, the exported
+// fields must be set before the Client is used and not changed afterwards.
+type Client struct {
+	mu         sync.Mutex
+	apiKey     string
+	apiCorrect bool
+
+	// HTTPClient is used to execute the requests, http.DefaultClient is
+	// used when nil
+	HTTPClient *http.Client
+
+	// Retry is the policy applied to the requests failing with a transient
+	// error, like a rate limit or a server error
+	Retry artwork.RetryPolicy
+
+	// BaseURL is the url the API methods are appended to, it can be changed
+	// to use a mirror of the API
+	BaseURL string
+}
+
+// defaultClient is used by the package level helper methods
+var defaultClient = NewClient("")
+
+// NewClient returns a new Client using the given Last.fm API Key
+func NewClient(key string) *Client {
+	return &Client{
+		apiKey:     url.QueryEscape(key),
+		HTTPClient: &http.Client{},
+		Retry:      artwork.DefaultRetryPolicy,
+		BaseURL:    DefaultBaseURL,
+	}
+}
+
 // The Result represents the specific size of artworks and contains the url of
 // each size of artwork returned by the Last.fm API
 type Result struct {
@@ -28,63 +77,145 @@ type Result struct {
 	Default    string
 }
 
+// Widths of the images of the Last.fm API by size label, the mega images have
+// no fixed size
+var imageWidths = map[string]int{
+	"small":      34,
+	"medium":     64,
+	"large":      174,
+	"extralarge": 300,
+}
+
+// Artwork converts the result to the Artwork shared by the service packages,
+// with the widths of the size labels of the Last.fm API
+func (r Result) Artwork() artwork.Artwork {
+	a := artwork.Artwork{Provider: artwork.LastFmName, Retrieved: time.Now()}
+	a.Add(artwork.Source{URL: r.Small, Width: imageWidths["small"], Height: imageWidths["small"]})
+	a.Add(artwork.Source{URL: r.Medium, Width: imageWidths["medium"], Height: imageWidths["medium"]})
+	a.Add(artwork.Source{URL: r.Large, Width: imageWidths["large"], Height: imageWidths["large"]})
+	a.Add(artwork.Source{URL: r.ExtraLarge, Width: imageWidths["extralarge"], Height: imageWidths["extralarge"]})
+	a.Add(artwork.Source{URL: r.Mega})
+	a.Add(artwork.Source{URL: r.Default})
+
+	return a
+}
+
+// Artwork converts the candidate to the Artwork shared by the service
+// packages, with the MusicBrainz id, name and artist of the match
+func (c Candidate) Artwork() artwork.Artwork {
+	a := c.Result.Artwork()
+	a.ID, a.Name, a.Artist = c.MBID, c.Name, c.Artist
+
+	return a
+}
+
 type image struct {
-	Size string `json:size`
+	Size string `json:"size"`
 	Url  string `json:"#text"`
 }
 
 type album struct {
-	Name  string  `json:name`
-	Image []image `json:image`
+	Name  string  `json:"name"`
+	Image []image `json:"image"`
 }
 
 type track struct {
-	Name  string `json:name`
-	Album *album `json:album`
+	Name  string `json:"name"`
+	Album *album `json:"album"`
 }
 
 type artist struct {
-	Name  string  `json:name`
-	Image []image `json:image`
+	Name  string  `json:"name"`
+	Image []image `json:"image"`
 }
 
 type httpResponse struct {
-	Album  *album  `json:album`
-	Artist *artist `json:artist`
-	Track  *track  `json:track`
+	Album  *album  `json:"album"`
+	Artist *artist `json:"artist"`
+	Track  *track  `json:"track"`
+}
+
+// The Candidate represents one of the matches of a search, with its metadata
+// and artworks. The artist of the artist matches is empty.
+type Candidate struct {
+	Name   string
+	Artist string
+
+	// MBID is the MusicBrainz id of the match, when Last.fm knows it
+	MBID   string
+	URL    string
+	Result Result
+}
+
+type match struct {
+	Name   string  `json:"name"`
+	Artist string  `json:"artist"`
+	Mbid   string  `json:"mbid"`
+	Url    string  `json:"url"`
+	Image  []image `json:"image"`
+}
+
+type matches struct {
+	Album  []match `json:"album"`
+	Track  []match `json:"track"`
+	Artist []match `json:"artist"`
+}
+
+type httpSearch struct {
+	Results *struct {
+		Albums  *matches `json:"albummatches"`
+		Tracks  *matches `json:"trackmatches"`
+		Artists *matches `json:"artistmatches"`
+	} `json:"results"`
 }
 
 type httpError struct {
-	Error   *int    `json:error`
-	Message *string `json:message`
+	Error   *int    `json:"error"`
+	Message *string `json:"message"`
 }
 
 // AutoCorrect activates the autocorrect parameter in the Last.fm query url to
 // notify the Last.fm API to fix spelling mistakes
 // Note: Result may not be as expected
-func AutoCorrect(act bool) {
-	apiCorrect = true
+func (c *Client) AutoCorrect(act bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.apiCorrect = act
 }
 
-// SetAPIKey provides a method to update the Last.fm API Key
-func SetAPIKey(key string) {
-	apiKey = url.QueryEscape(key)
+// SetAPIKey provides a method to update the Last.fm API Key of the client
+func (c *Client) SetAPIKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.apiKey = url.QueryEscape(key)
 }
 
-// Configure must be called before calling any other requests to set the Last.fm API Key
-func Configure(key string) {
-	apiKey = url.QueryEscape(key)
-	apiCorrect = false
+// Used to get the API Key and the autocorrect setting of the client, they may
+// be changed by other goroutines
+func (c *Client) settings() (apiKey string, autocorrect bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.apiKey, c.apiCorrect
 }
 
-// CheckAPIKey provides a simple method to verify if the API Key has been set and
-// if it is valid
-func CheckAPIKey() error {
+// CheckAPIKey provides a simple method to verify if the API Key of the client
+// has been set and if it is valid
+func (c *Client) CheckAPIKey() error {
+	return c.CheckAPIKeyContext(context.Background())
+}
+
+// CheckAPIKeyContext is like CheckAPIKey, but the request is bound to ctx so it
+// can be cancelled or given a deadline.
+func (c *Client) CheckAPIKeyContext(ctx context.Context) error {
+	apiKey, _ := c.settings()
 	if len(apiKey) == 0 {
-		return errors.New("API Key is not set")
+		return artwork.Unauthorized("API Key is not set")
 	}
 
-	_, err := request(checkApiUrl + apiKey)
+	_, err := c.request(ctx, c.BaseURL+checkApiUrl+apiKey)
 	if err != nil {
 		return err
 	}
@@ -92,6 +223,35 @@ func CheckAPIKey() error {
 	return nil
 }
 
+// AutoCorrect activates the autocorrect parameter of the default client
+func AutoCorrect(act bool) {
+	defaultClient.AutoCorrect(act)
+}
+
+// SetAPIKey provides a method to update the Last.fm API Key of the default
+// client
+func SetAPIKey(key string) {
+	defaultClient.SetAPIKey(key)
+}
+
+// Configure must be called before calling any other requests to set the Last.fm
+// API Key of the default client
+func Configure(key string) {
+	defaultClient.SetAPIKey(key)
+	defaultClient.AutoCorrect(false)
+}
+
+// CheckAPIKey verifies if the API Key of the default client has been set and
+// if it is valid
+func CheckAPIKey() error {
+	return defaultClient.CheckAPIKey()
+}
+
+// CheckAPIKeyContext is like CheckAPIKey, but the request is bound to ctx
+func CheckAPIKeyContext(ctx context.Context) error {
+	return defaultClient.CheckAPIKeyContext(ctx)
+}
+
 func setDefaultCover(res Result) Result {
 	if len(res.Default) > 0 {
 		return res
@@ -138,7 +298,7 @@ func buildResult(images []image) (Result, error) {
 	}
 
 	if !min {
-		return res, errors.New("No image was found")
+		return res, artwork.NotFound("No image was found")
 	}
 
 	return setDefaultCover(res), nil
@@ -156,7 +316,7 @@ func parseResults(data []byte, parse string) (Result, error) {
 
 	switch parse {
 	default:
-		return Result{}, errors.New("No image was found")
+		return Result{}, artwork.NotFound("No image was found")
 	case "album":
 		if resp.Album != nil {
 			return buildResult(resp.Album.Image)
@@ -171,14 +331,102 @@ func parseResults(data []byte, parse string) (Result, error) {
 		}
 	}
 
-	return Result{}, errors.New("No image was found")
+	return Result{}, artwork.NotFound("No image was found")
+}
+
+// Parse http search response and build the candidates which have an artwork,
+// in the order of the response
+// parse { album, artist, track }
+func parseCandidates(data []byte, parse string) ([]Candidate, error) {
+	resp := httpSearch{}
+
+	err := json.Unmarshal(data, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Results == nil {
+		return nil, artwork.NotFound("No match was found")
+	}
+
+	var list []match
+	switch r := resp.Results; {
+	case parse == "album" && r.Albums != nil:
+		list = r.Albums.Album
+	case parse == "artist" && r.Artists != nil:
+		list = r.Artists.Artist
+	case parse == "track" && r.Tracks != nil:
+		list = r.Tracks.Track
+	}
+
+	candidates := []Candidate{}
+	for _, m := range list {
+		res, err := buildResult(m.Image)
+		if err != nil {
+			continue
+		}
+
+		candidates = append(candidates, Candidate{
+			Name:   m.Name,
+			Artist: m.Artist,
+			MBID:   m.Mbid,
+			URL:    m.Url,
+			Result: res,
+		})
+	}
+
+	if len(candidates) == 0 {
+		return nil, artwork.NotFound("No match was found")
+	}
+
+	return candidates, nil
+}
+
+// Used to build the url of a search method, the limit is bounded to MaxLimit
+func (c *Client) searchUrl(method string, limit int) string {
+	switch {
+	case limit <= 0:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+
+	apiKey, _ := c.settings()
+	return c.BaseURL + apiUrl + method + "&api_key=" + apiKey + "&limit=" + strconv.Itoa(limit)
+}
+
+// Used to get the http client of the client
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
 }
 
-// Executes an http request and returns error or response body
-func request(url string) ([]byte, error) {
+// Executes an http request, retried according to the client policy, and
+// returns error or response body
+func (c *Client) request(ctx context.Context, url string) ([]byte, error) {
+	var body []byte
+
+	err := c.Retry.Do(ctx, func() error {
+		var err error
+		body, err = c.requestOnce(ctx, url)
+		return err
+	})
+
+	return body, err
+}
+
+// Executes a single http request and returns error or response body
+func (c *Client) requestOnce(ctx context.Context, url string) ([]byte, error) {
 	resErr := httpError{}
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -192,27 +440,69 @@ func request(url string) ([]byte, error) {
 
 	err = json.Unmarshal(body, &resErr)
 	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, artwork.ResponseError(resp, "")
+		}
+
 		return nil, err
 	}
 
 	if resErr.Error != nil {
-		err = errors.New(*resErr.Message)
+		message := resp.Status
+		if resErr.Message != nil {
+			message = *resErr.Message
+		}
+
+		return nil, apiError(resp, *resErr.Error, message)
 	}
 
-	return body, err
+	if resp.StatusCode != http.StatusOK {
+		return nil, artwork.ResponseError(resp, "")
+	}
+
+	return body, nil
+}
+
+// Used to convert the Last.fm error codes to the errors of the artwork package
+// See: https://www.last.fm/api/errorcodes
+func apiError(resp *http.Response, code int, message string) error {
+	switch code {
+	case 6, 7:
+		return artwork.NotFound(message)
+	case 4, 9, 10, 14, 26:
+		return artwork.Unauthorized(message)
+	case 29:
+		return &artwork.RateLimitError{
+			RetryAfter: artwork.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			Message:    message,
+		}
+	case 8, 11, 16:
+		// The transient errors are answered with a 200 status, they are
+		// reported as unavailable so that they are retried
+		return &artwork.UpstreamError{StatusCode: http.StatusServiceUnavailable, Message: message}
+	}
+
+	return &artwork.UpstreamError{StatusCode: resp.StatusCode, Message: message}
 }
 
 // AlbumCover gets the album artwork from the Last.fm database through out it's
 // dedicated API.
-func AlbumCover(album string, artist string) (Result, error) {
-	Url := apiUrl + "album.getinfo&api_key=" + apiKey + "&album="
+func (c *Client) AlbumCover(album string, artist string) (Result, error) {
+	return c.AlbumCoverContext(context.Background(), album, artist)
+}
+
+// AlbumCoverContext is like AlbumCover, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) AlbumCoverContext(ctx context.Context, album string, artist string) (Result, error) {
+	apiKey, autocorrect := c.settings()
+	Url := c.BaseURL + apiUrl + "album.getinfo&api_key=" + apiKey + "&album="
 	Url += url.QueryEscape(album) + "&artist=" + url.QueryEscape(artist)
 
-	if apiCorrect {
+	if autocorrect {
 		Url += "&autocorrect=1"
 	}
 
-	data, err := request(Url)
+	data, err := c.request(ctx, Url)
 	if err != nil {
 		return Result{}, err
 	}
@@ -222,15 +512,22 @@ func AlbumCover(album string, artist string) (Result, error) {
 
 // ArtistCover gets the artist artwork from the Last.fm database through out it's
 // dedicated API.
-func ArtistCover(artist string) (Result, error) {
-	Url := apiUrl + "artist.getinfo&api_key=" + apiKey + "&artist="
+func (c *Client) ArtistCover(artist string) (Result, error) {
+	return c.ArtistCoverContext(context.Background(), artist)
+}
+
+// ArtistCoverContext is like ArtistCover, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) ArtistCoverContext(ctx context.Context, artist string) (Result, error) {
+	apiKey, autocorrect := c.settings()
+	Url := c.BaseURL + apiUrl + "artist.getinfo&api_key=" + apiKey + "&artist="
 	Url += url.QueryEscape(artist)
 
-	if apiCorrect {
+	if autocorrect {
 		Url += "&autocorrect=1"
 	}
 
-	data, err := request(Url)
+	data, err := c.request(ctx, Url)
 	if err != nil {
 		return Result{}, err
 	}
@@ -240,18 +537,153 @@ func ArtistCover(artist string) (Result, error) {
 
 // TrackCover gets the track artwork from the Last.fm database through out it's
 // dedicated API.
-func TrackCover(track string, artist string) (Result, error) {
-	Url := apiUrl + "track.getinfo&api_key=" + apiKey + "&artist="
+func (c *Client) TrackCover(track string, artist string) (Result, error) {
+	return c.TrackCoverContext(context.Background(), track, artist)
+}
+
+// TrackCoverContext is like TrackCover, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) TrackCoverContext(ctx context.Context, track string, artist string) (Result, error) {
+	apiKey, autocorrect := c.settings()
+	Url := c.BaseURL + apiUrl + "track.getinfo&api_key=" + apiKey + "&artist="
 	Url += url.QueryEscape(artist) + "&track=" + url.QueryEscape(track)
 
-	if apiCorrect {
+	if autocorrect {
 		Url += "&autocorrect=1"
 	}
 
-	data, err := request(Url)
+	data, err := c.request(ctx, Url)
 	if err != nil {
 		return Result{}, err
 	}
 
 	return parseResults(data, "track")
 }
+
+// AlbumCandidates gets up to limit albums matching the query from the Last.fm
+// database, with their artworks, in the order of relevance of the API.
+// DefaultLimit candidates are requested when limit is zero.
+func (c *Client) AlbumCandidates(album string, artist string, limit int) ([]Candidate, error) {
+	return c.AlbumCandidatesContext(context.Background(), album, artist, limit)
+}
+
+// AlbumCandidatesContext is like AlbumCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) AlbumCandidatesContext(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	// album.search has no artist parameter, the artist narrows the query
+	query := strings.TrimSpace(album + " " + artist)
+
+	data, err := c.request(ctx, c.searchUrl("album.search", limit)+"&album="+url.QueryEscape(query))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCandidates(data, "album")
+}
+
+// ArtistCandidates gets up to limit artists matching the query from the
+// Last.fm database, with their artworks, in the order of relevance of the API.
+// DefaultLimit candidates are requested when limit is zero.
+func (c *Client) ArtistCandidates(artist string, limit int) ([]Candidate, error) {
+	return c.ArtistCandidatesContext(context.Background(), artist, limit)
+}
+
+// ArtistCandidatesContext is like ArtistCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) ArtistCandidatesContext(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	data, err := c.request(ctx, c.searchUrl("artist.search", limit)+"&artist="+url.QueryEscape(artist))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCandidates(data, "artist")
+}
+
+// TrackCandidates gets up to limit tracks matching the query from the Last.fm
+// database, with their artworks, in the order of relevance of the API.
+// DefaultLimit candidates are requested when limit is zero.
+func (c *Client) TrackCandidates(track string, artist string, limit int) ([]Candidate, error) {
+	return c.TrackCandidatesContext(context.Background(), track, artist, limit)
+}
+
+// TrackCandidatesContext is like TrackCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) TrackCandidatesContext(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	Url := c.searchUrl("track.search", limit) + "&track=" + url.QueryEscape(track)
+	if len(artist) > 0 {
+		Url += "&artist=" + url.QueryEscape(artist)
+	}
+
+	data, err := c.request(ctx, Url)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCandidates(data, "track")
+}
+
+// AlbumCover gets the album artwork with the default client
+func AlbumCover(album string, artist string) (Result, error) {
+	return defaultClient.AlbumCover(album, artist)
+}
+
+// AlbumCoverContext gets the album artwork with the default client, the request is
+// bound to ctx
+func AlbumCoverContext(ctx context.Context, album string, artist string) (Result, error) {
+	return defaultClient.AlbumCoverContext(ctx, album, artist)
+}
+
+// ArtistCover gets the artist artwork with the default client
+func ArtistCover(artist string) (Result, error) {
+	return defaultClient.ArtistCover(artist)
+}
+
+// ArtistCoverContext gets the artist artwork with the default client, the request is
+// bound to ctx
+func ArtistCoverContext(ctx context.Context, artist string) (Result, error) {
+	return defaultClient.ArtistCoverContext(ctx, artist)
+}
+
+// TrackCover gets the track artwork with the default client
+func TrackCover(track string, artist string) (Result, error) {
+	return defaultClient.TrackCover(track, artist)
+}
+
+// TrackCoverContext gets the track artwork with the default client, the request is
+// bound to ctx
+func TrackCoverContext(ctx context.Context, track string, artist string) (Result, error) {
+	return defaultClient.TrackCoverContext(ctx, track, artist)
+}
+
+// AlbumCandidates gets the album candidates with the default client
+func AlbumCandidates(album string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.AlbumCandidates(album, artist, limit)
+}
+
+// AlbumCandidatesContext gets the album candidates with the default client,
+// the request is bound to ctx
+func AlbumCandidatesContext(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.AlbumCandidatesContext(ctx, album, artist, limit)
+}
+
+// ArtistCandidates gets the artist candidates with the default client
+func ArtistCandidates(artist string, limit int) ([]Candidate, error) {
+	return defaultClient.ArtistCandidates(artist, limit)
+}
+
+// ArtistCandidatesContext gets the artist candidates with the default client,
+// the request is bound to ctx
+func ArtistCandidatesContext(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.ArtistCandidatesContext(ctx, artist, limit)
+}
+
+// TrackCandidates gets the track candidates with the default client
+func TrackCandidates(track string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.TrackCandidates(track, artist, limit)
+}
+
+// TrackCandidatesContext gets the track candidates with the default client,
+// the request is bound to ctx
+func TrackCandidatesContext(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	return defaultClient.TrackCandidatesContext(ctx, track, artist, limit)
+}
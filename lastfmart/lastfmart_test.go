@@ -1,9 +1,14 @@
 package lastfmart_test
 
 import (
+	"errors"
 	"fmt"
+	"github.com/piraveen/go-coverart/artwork"
 	"github.com/piraveen/go-coverart/lastfmart"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -67,6 +72,169 @@ func TestTrackCover(t *testing.T) {
 	}
 }
 
+func TestClientAPIKey(t *testing.T) {
+	client := lastfmart.NewClient("")
+
+	if err := client.CheckAPIKey(); !errors.Is(err, artwork.ErrUnauthorized) {
+		t.Errorf("expected an unauthorized error for a client without API Key, got %v", err)
+	}
+}
+
+func TestClientBaseURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("method") != "artist.getinfo" || q.Get("api_key") != "key" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		fmt.Fprint(w, `{"artist": {"name": "Ellie Goulding", "image": [
+			{"#text": "s.png", "size": "small"},
+			{"#text": "xl.png", "size": "extralarge"}
+		]}}`)
+	}))
+	defer ts.Close()
+
+	client := lastfmart.NewClient("key")
+	client.HTTPClient = ts.Client()
+	client.BaseURL = ts.URL + "/"
+
+	results, err := client.ArtistCover("ellie goulding")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if results.Small != "s.png" || results.ExtraLarge != "xl.png" || results.Default != "xl.png" {
+		t.Errorf("unexpected result %+v", results)
+	}
+}
+
+func TestClientConcurrency(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"artist": {"name": "Ellie Goulding", "image": [{"#text": "xl.png", "size": "extralarge"}]}}`)
+	}))
+	defer ts.Close()
+
+	client := lastfmart.NewClient("key")
+	client.HTTPClient = ts.Client()
+	client.BaseURL = ts.URL + "/"
+
+	// The API Key and the settings can change while the client is shared
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			client.SetAPIKey(fmt.Sprint("key", i))
+			client.AutoCorrect(i%2 == 0)
+			if _, err := client.ArtistCover("ellie goulding"); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestClientCandidates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("method") != "album.search" || q.Get("limit") != "5" || q.Get("album") != "halcyon days ellie goulding" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		fmt.Fprint(w, `{"results": {"albummatches": {"album": [
+			{"name": "Halcyon Days", "artist": "Ellie Goulding", "mbid": "1", "url": "https://www.last.fm/1",
+				"image": [{"#text": "s.png", "size": "small"}, {"#text": "xl.png", "size": "extralarge"}]},
+			{"name": "Halcyon Days (Live)", "artist": "Ellie Goulding", "image": [{"#text": "", "size": "small"}]},
+			{"name": "Halcyon", "artist": "Ellie Goulding", "image": [{"#text": "h.png", "size": "large"}]}
+		]}}}`)
+	}))
+	defer ts.Close()
+
+	client := lastfmart.NewClient("key")
+	client.BaseURL = ts.URL + "/"
+
+	candidates, err := client.AlbumCandidates("halcyon days", "ellie goulding", 5)
+	if err != nil || len(candidates) != 2 {
+		t.Fatalf("unexpected candidates %+v %v", candidates, err)
+	}
+
+	first := candidates[0]
+	if first.Name != "Halcyon Days" || first.Artist != "Ellie Goulding" || first.MBID != "1" || first.URL != "https://www.last.fm/1" || first.Result.Default != "xl.png" {
+		t.Errorf("unexpected candidate %+v", first)
+	}
+
+	if candidates[1].Name != "Halcyon" || candidates[1].Result.Large != "h.png" {
+		t.Errorf("unexpected candidate %+v", candidates[1])
+	}
+
+	a := first.Artwork()
+	if src, ok := a.Largest(); a.Provider != "lastfm" || a.ID != "1" || len(a.Images) != 2 || !ok || src.URL != "xl.png" || src.Width != 300 || src.Format != "png" {
+		t.Errorf("unexpected artwork %+v", a)
+	}
+
+	// The mega image, whose size is unknown, is the largest one
+	res := lastfmart.Result{Small: "s.png", Large: "l.png", ExtraLarge: "xl.png", Mega: "mega.png", Default: "mega.png"}
+	if src, ok := res.Artwork().Largest(); !ok || src.URL != "mega.png" {
+		t.Errorf("unexpected largest image %+v", src)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		body      string
+		expected  error
+		retryable bool
+	}{
+		{`{"error": 6, "message": "Album not found"}`, artwork.ErrNotFound, false},
+		{`{"error": 10, "message": "Invalid API key"}`, artwork.ErrUnauthorized, false},
+		{`{"error": 29, "message": "Rate limit exceeded"}`, artwork.ErrRateLimited, true},
+		{`{"error": 8, "message": "Operation failed"}`, artwork.ErrUpstream, true},
+		{`{"error": 11, "message": "Service Offline"}`, artwork.ErrUpstream, true},
+		{`{"error": 16, "message": "There was a temporary error processing your request"}`, artwork.ErrUpstream, true},
+		{`{"error": 13, "message": "Invalid method signature supplied"}`, artwork.ErrUpstream, false},
+		{`{"album": {"name": "Halcyon Days", "image": []}}`, artwork.ErrNotFound, false},
+	}
+
+	for _, test := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, test.body)
+		}))
+
+		client := lastfmart.NewClient("key")
+		client.BaseURL = ts.URL + "/"
+		client.Retry = artwork.NoRetry
+
+		_, err := client.AlbumCover("halcyon days", "ellie goulding")
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.body, test.expected, err)
+		}
+
+		if artwork.Retryable(err) != test.retryable {
+			t.Errorf("%s: expected retryable to be %v", test.body, test.retryable)
+		}
+
+		ts.Close()
+	}
+}
+
+func ExampleNewClient() {
+	// Every client owns its API Key and settings
+	client := lastfmart.NewClient("LASTFM_APIKEY")
+	client.AutoCorrect(true)
+
+	if err := client.CheckAPIKey(); err != nil {
+		// Abort action
+		return
+	}
+
+	results, err := client.AlbumCover("halcyon days", "ellie goulding")
+	if err == nil {
+		fmt.Printf("AlbumCover %v\n", results.Default)
+	}
+}
+
 func ExampleAlbumCover() {
 	// The API Keys can be defined in your code itself, however I recommend
 	// loading them through an environment variable like this:
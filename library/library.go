@@ -0,0 +1,369 @@
+// Package library scans music directories to fill in the missing album
+// artworks. The audio files are grouped into albums by directory and tags, the
+// albums lacking an artwork are looked up with any coverart Provider, usually
+// a Chain, by a pool of workers. The artwork is written as a folder.jpg file
+// and, optionally, embedded into the audio files.
+//
+// The scans are resumable: the processed albums are recorded in a state file,
+// and skipped by the next scans using the same file.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/piraveen/go-coverart"
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// Names of the artwork files looked for in the album directories
+var coverFiles = []string{"folder.jpg", "folder.png", "cover.jpg", "cover.png", "front.jpg", "front.png"}
+
+// The Album represents the audio files of a directory sharing the same album
+// tags
+type Album struct {
+	Dir   string
+	Files []string
+
+	// Tags are the tags of the first file of the album, used for the lookup.
+	// The album is the name of the directory when the files have no album
+	// tag, the artist the name of its parent when they have no artist tag.
+	Tags coverart.Tags
+
+	// Shared is true when the directory holds several albums, the artwork
+	// files of the directory are then ignored and the artwork is embedded
+	// into the audio files
+	Shared bool
+}
+
+// Key returns the key identifying the album in the state file
+func (a *Album) Key() string {
+	return a.Dir + "\x1f" + strings.ToLower(a.Tags.Album)
+}
+
+// Status is the outcome of the processing of an album
+type Status int
+
+// Outcomes of the processing of an album
+const (
+	// HasArtwork means that the album already has an artwork file or
+	// embedded artworks
+	HasArtwork Status = iota
+
+	// Written means that the artwork was found and written
+	Written
+
+	// NotFound means that no provider has the artwork of the album, it is
+	// looked up again by the next scans unless they skip the albums not found
+	NotFound
+
+	// Failed means that the lookup or the writing of the artwork failed, the
+	// album is processed again by the next scan
+	Failed
+
+	// Resumed means that the album was processed by a previous scan
+	Resumed
+)
+
+var statusNames = []string{"has artwork", "written", "not found", "failed", "resumed"}
+
+// String returns the name of the status
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+
+	return statusNames[s]
+}
+
+// The Report represents the outcome of the processing of an album
+type Report struct {
+	Album  *Album
+	Status Status
+	Result coverart.Result
+	Err    error
+}
+
+// The Summary represents the number of albums by outcome of a scan
+type Summary map[Status]int
+
+// The Scanner fills in the missing artworks of the albums of a directory
+type Scanner struct {
+	// Provider looks up the artworks of the albums
+	Provider coverart.Provider
+
+	// Workers is the number of albums processed concurrently
+	Workers int
+
+	// StateFile is the path of the file recording the processed albums. The
+	// scan is not resumable when it is empty.
+	StateFile string
+
+	// SkipNotFound skips the albums not found by the previous scans as well,
+	// instead of looking them up again in case a provider has them since
+	SkipNotFound bool
+
+	// Embed writes the artwork into the audio files as well, when their
+	// format supports it
+	Embed bool
+
+	// Resize normalizes the artworks before they are written when not nil
+	Resize *artwork.ResizeOptions
+
+	// Fetcher downloads the artworks, the default Fetcher is used when nil
+	Fetcher *artwork.Fetcher
+
+	// Progress is called with the report of every album when not nil, one
+	// call at a time
+	Progress func(Report)
+}
+
+// NewScanner returns a Scanner looking up the artworks with the provider, with
+// 4 workers
+func NewScanner(p coverart.Provider) *Scanner {
+	return &Scanner{Provider: p, Workers: 4}
+}
+
+// Albums lists the albums of the audio files found under the root directory.
+// The files whose tags can not be read are ignored.
+func Albums(root string) ([]*Album, error) {
+	albums := []*Album{}
+	byKey := map[string]*Album{}
+	dirs := map[string]int{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		tags, err := coverart.ReadTags(path)
+		if err != nil {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		if len(tags.Album) == 0 {
+			tags.Album = filepath.Base(dir)
+		}
+
+		if len(tags.Artist) == 0 && len(tags.AlbumArtist) == 0 {
+			tags.Artist = filepath.Base(filepath.Dir(dir))
+		}
+
+		a := &Album{Dir: dir, Tags: *tags}
+		if existing, ok := byKey[a.Key()]; ok {
+			existing.Files = append(existing.Files, path)
+			return nil
+		}
+
+		a.Files = []string{path}
+		byKey[a.Key()] = a
+		albums = append(albums, a)
+		dirs[dir]++
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range albums {
+		a.Shared = dirs[a.Dir] > 1
+	}
+
+	return albums, nil
+}
+
+// Used to check if the album has an artwork file or if all its audio files
+// have an embedded artwork
+func hasArtwork(a *Album) bool {
+	if !a.Shared {
+		// The names are compared regardless of case, like Cover.jpg or
+		// FOLDER.JPG written by other tools
+		infos, _ := ioutil.ReadDir(a.Dir)
+		for _, info := range infos {
+			for _, name := range coverFiles {
+				if !info.IsDir() && strings.EqualFold(info.Name(), name) {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, path := range a.Files {
+		if ok, err := coverart.HasCover(path); err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Used to write the artwork of the album, as an artwork file of the directory
+// and into the audio files
+func (s *Scanner) write(a *Album, img *artwork.Image) error {
+	written := false
+
+	if !a.Shared {
+		name := "folder.jpg"
+		if img.Format == "png" {
+			name = "folder.png"
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(a.Dir, name), img.Data, 0644); err != nil {
+			return err
+		}
+
+		written = true
+	}
+
+	if !s.Embed && !a.Shared {
+		return nil
+	}
+
+	for _, path := range a.Files {
+		if ok, err := coverart.HasCover(path); err != nil || ok {
+			continue
+		}
+
+		if err := coverart.Embed(path, img, false); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		written = true
+	}
+
+	if !written {
+		return errors.New("None of the audio files can hold an artwork")
+	}
+
+	return nil
+}
+
+// Used to look up, download and write the artwork of the album
+func (s *Scanner) process(ctx context.Context, a *Album) Report {
+	r := Report{Album: a, Status: HasArtwork}
+	if hasArtwork(a) {
+		return r
+	}
+
+	r.Result, r.Err = coverart.LookupTags(ctx, s.Provider, &a.Tags)
+	if errors.Is(r.Err, artwork.ErrNotFound) {
+		r.Status = NotFound
+		return r
+	}
+
+	var img *artwork.Image
+	if r.Err == nil {
+		fetcher := s.Fetcher
+		if fetcher == nil {
+			fetcher = artwork.NewFetcher()
+		}
+
+		img, r.Err = fetcher.Fetch(ctx, r.Result.URL())
+	}
+
+	if r.Err == nil && s.Resize != nil {
+		img, r.Err = artwork.Resize(img, *s.Resize)
+	}
+
+	if r.Err == nil {
+		r.Err = s.write(a, img)
+	}
+
+	r.Status = Written
+	if r.Err != nil {
+		r.Status = Failed
+	}
+
+	return r
+}
+
+// Scan fills in the missing artworks of the albums found under the root
+// directory. The outcome of every album is given to the Progress function,
+// the errors of the albums are not returned. The scan stops early when ctx is
+// cancelled, the albums already processed are then recorded in the state
+// file.
+func (s *Scanner) Scan(ctx context.Context, root string) (Summary, error) {
+	albums, err := Albums(root)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(albums, func(i, j int) bool {
+		return albums[i].Key() < albums[j].Key()
+	})
+
+	st, err := openState(s.StateFile, s.SkipNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	defer st.close()
+
+	summary := Summary{}
+	mu := sync.Mutex{}
+	report := func(r Report) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		summary[r.Status]++
+		if r.Status != Failed && r.Status != Resumed {
+			if err := st.record(r.Album.Key(), r.Status); err != nil && r.Err == nil {
+				r.Err = err
+			}
+		}
+
+		if s.Progress != nil {
+			s.Progress(r)
+		}
+	}
+
+	queue := make(chan *Album)
+	wg := sync.WaitGroup{}
+	for i := 0; i < max(s.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range queue {
+				report(s.process(ctx, a))
+			}
+		}()
+	}
+
+	for _, a := range albums {
+		if st.isDone(a.Key()) {
+			report(Report{Album: a, Status: Resumed})
+			continue
+		}
+
+		select {
+		case queue <- a:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return summary, ctx.Err()
+}
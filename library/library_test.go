@@ -0,0 +1,191 @@
+package library_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/piraveen/go-coverart"
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/library"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var audio = bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x64}, 256)
+
+// fakeProvider finds the artwork of every album but "Unknown"
+type fakeProvider struct {
+	url     string
+	mu      sync.Mutex
+	queries []string
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Album(ctx context.Context, album string, artist string) (coverart.Result, error) {
+	p.mu.Lock()
+	p.queries = append(p.queries, artist+"/"+album)
+	p.mu.Unlock()
+
+	if album == "Unknown" {
+		return coverart.Result{}, artwork.NotFound("unknown album")
+	}
+
+	return coverart.Result{Provider: "fake", Large: p.url}, nil
+}
+
+func (p *fakeProvider) Track(ctx context.Context, track string, artist string) (coverart.Result, error) {
+	return coverart.Result{}, artwork.NotFound("no track")
+}
+
+func (p *fakeProvider) Artist(ctx context.Context, artist string) (coverart.Result, error) {
+	return coverart.Result{}, artwork.NotFound("no artist")
+}
+
+// musicDir builds a music directory, the albums are named after their
+// directories as the files have no tags
+func musicDir(t *testing.T, files ...string) string {
+	root := t.TempDir()
+
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+
+		if err := ioutil.WriteFile(path, audio, 0644); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	return root
+}
+
+func server(t *testing.T) *httptest.Server {
+	buf := bytes.Buffer{}
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestAlbums(t *testing.T) {
+	root := musicDir(t, "Ellie Goulding/Halcyon/01.mp3", "Ellie Goulding/Halcyon/02.mp3", "Ellie Goulding/Lights/01.flac", "Ellie Goulding/Lights/notes.txt")
+
+	// The FLAC file is not valid, its tags can not be read
+	albums, err := library.Albums(root)
+	if err != nil || len(albums) != 1 {
+		t.Fatalf("unexpected albums %v %v", albums, err)
+	}
+
+	expected := coverart.Tags{Album: "Halcyon", Artist: "Ellie Goulding"}
+	if a := albums[0]; a.Tags != expected || len(a.Files) != 2 || a.Shared {
+		t.Errorf("unexpected album %+v", a)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := musicDir(t, "Ellie Goulding/Halcyon/01.mp3", "Ellie Goulding/Lights/01.mp3", "Ellie Goulding/Unknown/01.mp3", "Ellie Goulding/Delirium/01.mp3")
+	// The names of the artwork files are not case sensitive
+	ioutil.WriteFile(filepath.Join(root, "Ellie Goulding/Delirium/Cover.jpg"), []byte("jpeg data"), 0644)
+
+	p := &fakeProvider{url: server(t).URL + "/cover.png"}
+	scanner := library.NewScanner(p)
+	scanner.StateFile = filepath.Join(t.TempDir(), "state")
+	scanner.Embed = true
+
+	reports := map[string]library.Status{}
+	scanner.Progress = func(r library.Report) {
+		reports[r.Album.Tags.Album] = r.Status
+		if r.Err != nil && r.Status != library.NotFound {
+			t.Errorf("%s: unexpected error %v", r.Album.Tags.Album, r.Err)
+		}
+	}
+
+	summary, err := scanner.Scan(context.Background(), root)
+	if err != nil || summary[library.Written] != 2 || summary[library.NotFound] != 1 || summary[library.HasArtwork] != 1 {
+		t.Fatalf("unexpected summary %v %v", summary, err)
+	}
+
+	if reports["Halcyon"] != library.Written || reports["Unknown"] != library.NotFound || reports["Delirium"] != library.HasArtwork {
+		t.Errorf("unexpected reports %v", reports)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "Ellie Goulding/Halcyon/folder.png")); err != nil {
+		t.Errorf("expected the artwork file to be written, got %v", err)
+	}
+
+	if ok, _ := coverart.HasCover(filepath.Join(root, "Ellie Goulding/Lights/01.mp3")); !ok {
+		t.Errorf("expected the artwork to be embedded")
+	}
+
+	// The next scan resumes, only the album not found is looked up again
+	p.queries = nil
+	summary, err = scanner.Scan(context.Background(), root)
+	if err != nil || summary[library.Resumed] != 3 || summary[library.NotFound] != 1 || len(p.queries) == 0 {
+		t.Errorf("unexpected summary of the resumed scan %v %v %q", summary, err, p.queries)
+	}
+
+	// Unless the albums not found are skipped as well
+	p.queries = nil
+	scanner.SkipNotFound = true
+	summary, err = scanner.Scan(context.Background(), root)
+	if err != nil || summary[library.Resumed] != 4 || len(p.queries) != 0 {
+		t.Errorf("unexpected summary of the resumed scan %v %v %q", summary, err, p.queries)
+	}
+}
+
+func TestScanSharedDirectory(t *testing.T) {
+	root := musicDir(t, "Various/01.mp3")
+
+	// A second album in the same directory, with an ID3v2.3 album frame
+	album := "\x00Halcyon"
+	frame := append([]byte{'T', 'A', 'L', 'B', 0, 0, 0, byte(len(album)), 0, 0}, album...)
+	tag := append([]byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, byte(len(frame))}, frame...)
+	ioutil.WriteFile(filepath.Join(root, "Various/02.mp3"), append(tag, audio...), 0644)
+
+	p := &fakeProvider{url: server(t).URL + "/cover.png"}
+	summary, err := library.NewScanner(p).Scan(context.Background(), root)
+	if err != nil || summary[library.Written] != 2 {
+		t.Fatalf("unexpected summary %v %v", summary, err)
+	}
+
+	// No artwork file is written in a shared directory, the artworks are
+	// embedded
+	if _, err := os.Stat(filepath.Join(root, "Various/folder.png")); err == nil {
+		t.Errorf("expected no artwork file")
+	}
+
+	for _, name := range []string{"01.mp3", "02.mp3"} {
+		if ok, _ := coverart.HasCover(filepath.Join(root, "Various", name)); !ok {
+			t.Errorf("expected the artwork to be embedded into %s", name)
+		}
+	}
+}
+
+func ExampleScanner() {
+	chain := coverart.NewChain(coverart.Itunes(), coverart.Spotify())
+
+	scanner := library.NewScanner(chain)
+	scanner.StateFile = "coverart.state"
+	scanner.Progress = func(r library.Report) {
+		fmt.Println(r.Album.Dir, r.Status, r.Err)
+	}
+
+	summary, err := scanner.Scan(context.Background(), "/music")
+	if err == nil {
+		fmt.Println(summary[library.Written], "artworks written")
+	}
+}
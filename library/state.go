@@ -0,0 +1,98 @@
+package library
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// The entry represents a line of the state file, one per processed album
+type entry struct {
+	Key    string    `json:"key"`
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// The state represents the albums processed by the previous scans. The state
+// file is only appended to, so that an interrupted scan loses at most the
+// line being written.
+type state struct {
+	done map[string]bool
+	file *os.File
+}
+
+// Used to read the state file and open it for appending, the state is empty
+// and not recorded when path is empty. The albums not found by the previous
+// scans are only done when skipNotFound is true.
+func openState(path string, skipNotFound bool) (*state, error) {
+	st := &state{done: map[string]bool{}}
+	if len(path) == 0 {
+		return st, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		e := entry{}
+
+		// A truncated line is ignored, the album is processed again
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+
+		if e.Status != NotFound.String() || skipNotFound {
+			st.done[e.Key] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Terminate the line truncated by an interrupted scan
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+
+	st.file = f
+	return st, nil
+}
+
+// Used to check if the album was processed by a previous scan
+func (st *state) isDone(key string) bool {
+	return st.done[key]
+}
+
+// Used to record a processed album, the albums recorded during a scan are
+// only skipped by the next scans
+func (st *state) record(key string, status Status) error {
+	if st.file == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry{Key: key, Status: status.String(), Time: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	_, err = st.file.Write(append(data, '\n'))
+	return err
+}
+
+func (st *state) close() error {
+	if st.file == nil {
+		return nil
+	}
+
+	return st.file.Close()
+}
@@ -0,0 +1,186 @@
+package coverart
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// DefaultThreshold is the confidence below which the matches are rejected by
+// the Matcher returned by NewMatcher
+const DefaultThreshold = 0.6
+
+// Score returns the confidence that the candidate matches the queried name and
+// artist, from 0 to 1. The name weighs more than the artist, which is only
+// compared when both are known. A candidate credited to several artists is
+// compared with each of them.
+func Score(name string, artist string, c Candidate) float64 {
+	score := artwork.Similarity(name, c.Name)
+	if len(artist) == 0 || len(c.Artist) == 0 {
+		return score
+	}
+
+	artistScore := artwork.Similarity(artist, c.Artist)
+	for _, a := range strings.Split(c.Artist, ", ") {
+		artistScore = max(artistScore, artwork.Similarity(artist, a))
+	}
+
+	return 0.6*score + 0.4*artistScore
+}
+
+// The Matcher verifies the matches of its Provider against the query. The
+// candidates of the provider are scored with Score, the ones below the
+// threshold are rejected and the best one is returned, with its score as the
+// Confidence of the Result. A Matcher implements the Provider and Searcher
+// interfaces.
+//
+// The providers which do not implement Searcher can not be verified, their
+// results are returned as is, with a zero Confidence.
+type Matcher struct {
+	Provider Provider
+
+	// Threshold is the lowest score of the accepted matches
+	Threshold float64
+
+	// Limit is the number of candidates requested from the provider for every
+	// lookup, the default of the service is used when zero
+	Limit int
+}
+
+// NewMatcher returns a Matcher verifying the matches of the provider with the
+// DefaultThreshold, out of 10 candidates
+func NewMatcher(p Provider) *Matcher {
+	return &Matcher{Provider: p, Threshold: DefaultThreshold, Limit: 10}
+}
+
+// Name returns the name of the provider of the matcher
+func (m *Matcher) Name() string {
+	return m.Provider.Name()
+}
+
+// Used to score the candidates, and to sort the accepted ones from the best
+// to the worst match
+func (m *Matcher) rank(name string, artist string, candidates []Candidate, err error) ([]Candidate, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := []Candidate{}
+	for _, c := range candidates {
+		c.Score = Score(name, artist, c)
+		c.Result.Confidence = c.Score
+
+		if c.Score >= m.Threshold {
+			accepted = append(accepted, c)
+		}
+	}
+
+	if len(accepted) == 0 {
+		return nil, artwork.NotFound("No match is close enough to the query")
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].Score > accepted[j].Score
+	})
+
+	return accepted, nil
+}
+
+// Used to search the candidates with the provider, the lookup is used instead
+// when the provider can not list candidates, or on the providers of a chain
+// which could not when the others found nothing. Only the artworks of the best
+// candidate are verified.
+func (m *Matcher) best(ctx context.Context, search func() ([]Candidate, error), lookup func(Provider) (Result, error)) (Result, error) {
+	candidates, err := search()
+
+	var unsearched *unsearchedError
+	if errors.As(err, &unsearched) {
+		return lookup(unsearched.chain)
+	}
+
+	if errors.Is(err, errNoSearcher) {
+		return lookup(m.Provider)
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return m.Verify(ctx, candidates[0].Result), nil
+}
+
+// Verify checks the artworks of the result with the provider, when it
+// implements Verifier
+func (m *Matcher) Verify(ctx context.Context, res Result) Result {
+	if v, ok := m.Provider.(Verifier); ok {
+		return v.Verify(ctx, res)
+	}
+
+	return res
+}
+
+// AlbumCandidates gets the albums matching the query from the provider, with
+// their score, without the ones below the threshold
+func (m *Matcher) AlbumCandidates(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	s, ok := m.Provider.(Searcher)
+	if !ok {
+		return nil, errNoSearcher
+	}
+
+	candidates, err := s.AlbumCandidates(ctx, album, artist, limit)
+	return m.rank(album, artist, candidates, err)
+}
+
+// TrackCandidates gets the tracks matching the query from the provider, with
+// their score, without the ones below the threshold
+func (m *Matcher) TrackCandidates(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	s, ok := m.Provider.(Searcher)
+	if !ok {
+		return nil, errNoSearcher
+	}
+
+	candidates, err := s.TrackCandidates(ctx, track, artist, limit)
+	return m.rank(track, artist, candidates, err)
+}
+
+// ArtistCandidates gets the artists matching the query from the provider, with
+// their score, without the ones below the threshold
+func (m *Matcher) ArtistCandidates(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	s, ok := m.Provider.(Searcher)
+	if !ok {
+		return nil, errNoSearcher
+	}
+
+	candidates, err := s.ArtistCandidates(ctx, artist, limit)
+	return m.rank(artist, "", candidates, err)
+}
+
+// Album gets the artwork of the best album matching the query
+func (m *Matcher) Album(ctx context.Context, album string, artist string) (Result, error) {
+	return m.best(ctx, func() ([]Candidate, error) {
+		return m.AlbumCandidates(ctx, album, artist, m.Limit)
+	}, func(p Provider) (Result, error) {
+		return p.Album(ctx, album, artist)
+	})
+}
+
+// Track gets the artwork of the best track matching the query
+func (m *Matcher) Track(ctx context.Context, track string, artist string) (Result, error) {
+	return m.best(ctx, func() ([]Candidate, error) {
+		return m.TrackCandidates(ctx, track, artist, m.Limit)
+	}, func(p Provider) (Result, error) {
+		return p.Track(ctx, track, artist)
+	})
+}
+
+// Artist gets the artwork of the best artist matching the query
+func (m *Matcher) Artist(ctx context.Context, artist string) (Result, error) {
+	return m.best(ctx, func() ([]Candidate, error) {
+		return m.ArtistCandidates(ctx, artist, m.Limit)
+	}, func(p Provider) (Result, error) {
+		return p.Artist(ctx, artist)
+	})
+}
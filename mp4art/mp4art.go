@@ -0,0 +1,473 @@
+// Package mp4art provides few helper methods to embed the artworks found by
+// the coverart packages into MP4 and M4A files, as the covr atom of the iTunes
+// metadata
+package mp4art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// Type indicators of the data atoms
+const (
+	typeUTF8 = 1
+	typeJPEG = 13
+	typePNG  = 14
+)
+
+// Error values returned by the helper methods
+var (
+	// ErrCoverExists is returned by Embed when the covr atom of the iTunes
+	// metadata already holds a picture, and replace is false
+	ErrCoverExists = errors.New("The file already has a cover")
+
+	// ErrInvalidFile is returned for the files which are not valid MP4 files
+	ErrInvalidFile = errors.New("Invalid MP4 file")
+)
+
+// Atoms holding other atoms, which are parsed down to the metadata and to the
+// chunk offset tables
+var containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"udta": true,
+	"meta": true,
+	"ilst": true,
+}
+
+type atom struct {
+	kind string
+
+	// data is the payload of the atoms which are not parsed, or the version
+	// and flags preceding the children of the meta atom
+	data     []byte
+	children []*atom
+}
+
+// The position of a top level atom in the file
+type section struct {
+	kind   string
+	offset int64
+	size   int64
+}
+
+// Used to list the top level atoms of the file
+func readSections(r io.ReaderAt, size int64) ([]section, error) {
+	sections := []section{}
+	header := make([]byte, 16)
+
+	for offset := int64(0); offset < size; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, ErrInvalidFile
+		}
+
+		s := section{kind: string(header[4:8]), offset: offset}
+		s.size = int64(binary.BigEndian.Uint32(header))
+
+		switch s.size {
+		case 0:
+			s.size = size - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, ErrInvalidFile
+			}
+
+			s.size = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+
+		if s.size < 8 || offset+s.size > size {
+			return nil, ErrInvalidFile
+		}
+
+		sections = append(sections, s)
+		offset += s.size
+	}
+
+	return sections, nil
+}
+
+// Used to parse the atoms of a payload, the containers are parsed recursively
+func parseAtoms(data []byte, parent string) ([]*atom, error) {
+	atoms := []*atom{}
+
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, ErrInvalidFile
+		}
+
+		size := uint64(binary.BigEndian.Uint32(data))
+		a := &atom{kind: string(data[4:8])}
+		header := uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, ErrInvalidFile
+			}
+
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+
+		if size < header || size > uint64(len(data)) {
+			return nil, ErrInvalidFile
+		}
+
+		payload := data[header:size]
+		data = data[size:]
+
+		// The items of the metadata list are containers of data atoms
+		if !containers[a.kind] && parent != "ilst" {
+			a.data = payload
+			atoms = append(atoms, a)
+			continue
+		}
+
+		// The meta atom is a full atom, except in some QuickTime files
+		if a.kind == "meta" && len(payload) >= 8 && string(payload[4:8]) != "hdlr" {
+			a.data, payload = payload[:4], payload[4:]
+		}
+
+		children, err := parseAtoms(payload, a.kind)
+		if err != nil {
+			return nil, err
+		}
+
+		a.children = children
+		atoms = append(atoms, a)
+	}
+
+	return atoms, nil
+}
+
+// Used to encode the atom and its children
+func (a *atom) encode() []byte {
+	payload := bytes.Buffer{}
+	payload.Write(a.data)
+
+	for _, c := range a.children {
+		payload.Write(c.encode())
+	}
+
+	header := make([]byte, 8)
+	copy(header[4:], a.kind)
+
+	if payload.Len()+8 > math.MaxUint32 {
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint32(header, 1)
+		binary.BigEndian.PutUint64(header[8:], uint64(payload.Len()+16))
+	} else {
+		binary.BigEndian.PutUint32(header, uint32(payload.Len()+8))
+	}
+
+	return append(header, payload.Bytes()...)
+}
+
+// Used to find the child of the given kind, nil if there is none
+func (a *atom) child(kind string) *atom {
+	for _, c := range a.children {
+		if c.kind == kind {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Used to find the child of the given kind, which is added when missing
+func (a *atom) childOrNew(kind string, data []byte) *atom {
+	if c := a.child(kind); c != nil {
+		return c
+	}
+
+	c := &atom{kind: kind, data: data}
+	a.children = append(a.children, c)
+	return c
+}
+
+// Used to follow a path of atoms, nil if one of them is missing
+func (a *atom) find(path ...string) *atom {
+	for _, kind := range path {
+		if a = a.child(kind); a == nil {
+			return nil
+		}
+	}
+
+	return a
+}
+
+// Used to shift by delta the chunk offsets of every track which point after
+// from, the media data before the moov atom does not move
+func (a *atom) shiftOffsets(from int64, delta int64) error {
+	for _, c := range a.children {
+		if err := c.shiftOffsets(from, delta); err != nil {
+			return err
+		}
+	}
+
+	if a.kind != "stco" && a.kind != "co64" {
+		return nil
+	}
+
+	if len(a.data) < 8 {
+		return ErrInvalidFile
+	}
+
+	width := 4
+	if a.kind == "co64" {
+		width = 8
+	}
+
+	count := int(binary.BigEndian.Uint32(a.data[4:8]))
+	if len(a.data) < 8+count*width {
+		return ErrInvalidFile
+	}
+
+	data := append([]byte{}, a.data...)
+	for i := 0; i < count; i++ {
+		entry := data[8+i*width:]
+
+		if width == 8 {
+			if offset := int64(binary.BigEndian.Uint64(entry)); offset >= from {
+				binary.BigEndian.PutUint64(entry, uint64(offset+delta))
+			}
+
+			continue
+		}
+
+		offset := int64(binary.BigEndian.Uint32(entry))
+		if offset < from {
+			continue
+		}
+
+		if offset += delta; offset > math.MaxUint32 {
+			return fmt.Errorf("Chunk offset %d overflows the stco atom", offset)
+		}
+
+		binary.BigEndian.PutUint32(entry, uint32(offset))
+	}
+
+	a.data = data
+	return nil
+}
+
+type file struct {
+	sections []section
+	moov     *atom
+	index    int
+}
+
+// Used to read the sections of the file and parse its moov atom
+func readFile(f *os.File) (*file, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := readSections(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range sections {
+		if s.kind != "moov" {
+			continue
+		}
+
+		data := make([]byte, s.size)
+		if _, err := f.ReadAt(data, s.offset); err != nil {
+			return nil, err
+		}
+
+		atoms, err := parseAtoms(data, "")
+		if err != nil {
+			return nil, err
+		}
+
+		return &file{sections, atoms[0], i}, nil
+	}
+
+	return nil, fmt.Errorf("%w: no moov atom", ErrInvalidFile)
+}
+
+func open(path string) (*file, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return readFile(f)
+}
+
+// Used to get the first picture of the covr atom, nil if there is none
+func (m *file) cover() *atom {
+	covr := m.moov.find("udta", "meta", "ilst", "covr")
+	if covr == nil {
+		return nil
+	}
+
+	return covr.child("data")
+}
+
+// HasCover reports if the MP4 file has a cover in its iTunes metadata
+func HasCover(path string) (bool, error) {
+	m, err := open(path)
+	if err != nil {
+		return false, err
+	}
+
+	return m.cover() != nil, nil
+}
+
+// ReadCover returns the picture data and MIME type of the cover of the file.
+// The error matches artwork.ErrNotFound when there is none.
+func ReadCover(path string) ([]byte, string, error) {
+	m, err := open(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data := m.cover()
+	if data == nil || len(data.data) < 8 {
+		return nil, "", artwork.NotFound("No cover was found")
+	}
+
+	mimeType := "image/jpeg"
+	if binary.BigEndian.Uint32(data.data) == typePNG {
+		mimeType = "image/png"
+	}
+
+	return data.data[8:], mimeType, nil
+}
+
+// ReadTags returns the album, artists and title of the iTunes metadata of the
+// file. The tags are empty when the file has no metadata.
+func ReadTags(path string) (*artwork.Tags, error) {
+	m, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := &artwork.Tags{}
+	ilst := m.moov.find("udta", "meta", "ilst")
+	if ilst == nil {
+		return tags, nil
+	}
+
+	fields := map[string]*string{
+		"\xa9alb": &tags.Album,
+		"aART":    &tags.AlbumArtist,
+		"\xa9ART": &tags.Artist,
+		"\xa9nam": &tags.Title,
+	}
+
+	for _, item := range ilst.children {
+		dst, ok := fields[item.kind]
+		data := item.child("data")
+
+		// The values are UTF-8 strings, after the type indicator and locale
+		if ok && data != nil && len(data.data) >= 8 && binary.BigEndian.Uint32(data.data) == typeUTF8 {
+			*dst = strings.TrimSpace(string(data.data[8:]))
+		}
+	}
+
+	return tags, nil
+}
+
+// Used to build the data atom of the picture
+func pictureData(img *artwork.Image) *atom {
+	data := make([]byte, 8, 8+len(img.Data))
+	binary.BigEndian.PutUint32(data, typeJPEG)
+	if img.Format == "png" {
+		binary.BigEndian.PutUint32(data, typePNG)
+	}
+
+	return &atom{kind: "data", data: append(data, img.Data...)}
+}
+
+// Used to build the handler of the iTunes metadata
+func metadataHandler() *atom {
+	data := make([]byte, 25)
+	copy(data[8:], "mdir")
+	copy(data[12:], "appl")
+
+	return &atom{kind: "hdlr", data: data}
+}
+
+// Embed writes the artwork into the covr atom of the iTunes metadata of the
+// MP4 file, which is created if needed. The other atoms are preserved. An
+// existing cover is only replaced when replace is true, otherwise
+// ErrCoverExists is returned.
+//
+// The chunk offsets of the stco and co64 atoms pointing after the moov atom are
+// updated when it changes size, the media data preceding it does not move.
+func Embed(path string, img *artwork.Image, replace bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	m, err := readFile(f)
+	if err != nil {
+		return err
+	}
+
+	if m.cover() != nil && !replace {
+		return ErrCoverExists
+	}
+
+	udta := m.moov.childOrNew("udta", nil)
+	meta := udta.child("meta")
+	if meta == nil {
+		meta = &atom{kind: "meta", data: make([]byte, 4)}
+		meta.children = []*atom{metadataHandler()}
+		udta.children = append(udta.children, meta)
+	}
+
+	ilst := meta.childOrNew("ilst", nil)
+	covr := ilst.childOrNew("covr", nil)
+	covr.children = []*atom{pictureData(img)}
+
+	// The media data following the moov atom is moved by delta
+	old := m.sections[m.index]
+	delta := int64(len(m.moov.encode())) - old.size
+	if err := m.moov.shiftOffsets(old.offset+old.size, delta); err != nil {
+		return err
+	}
+
+	// The moov atom takes the place of the original one in a new file
+	moov := m.moov.encode()
+	return artwork.ReplaceFile(path, func(w io.Writer) error {
+		for i, s := range m.sections {
+			var err error
+			if i == m.index {
+				_, err = w.Write(moov)
+			} else {
+				_, err = io.Copy(w, io.NewSectionReader(f, s.offset, s.size))
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
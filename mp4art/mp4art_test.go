@@ -0,0 +1,261 @@
+package mp4art_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/mp4art"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+var audio = bytes.Repeat([]byte("AAC frame "), 64)
+
+// box builds an atom of the given kind holding the payloads
+func box(kind string, payloads ...[]byte) []byte {
+	data := bytes.Join(payloads, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)+8))
+	copy(header[4:], kind)
+
+	return append(header, data...)
+}
+
+// chunkOffsets builds a stco or co64 atom holding the chunk offsets
+func chunkOffsets(kind string, offsets ...uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[4:], uint32(len(offsets)))
+
+	for _, offset := range offsets {
+		if kind == "co64" {
+			data = binary.BigEndian.AppendUint64(data, offset)
+		} else {
+			data = binary.BigEndian.AppendUint32(data, uint32(offset))
+		}
+	}
+
+	return box(kind, data)
+}
+
+// movie builds a file with a single track whose chunk offset points to the
+// audio data of the mdat atom, either preceded or followed by the moov atom
+func movie(kind string, faststart bool) []byte {
+	ftyp := box("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42isom"))
+	mdat := box("mdat", audio)
+
+	moov := func(offset uint64) []byte {
+		stbl := box("stbl", box("stsd", make([]byte, 8)), chunkOffsets(kind, offset))
+		trak := box("trak", box("tkhd", make([]byte, 84)), box("mdia", box("minf", stbl)))
+		return box("moov", box("mvhd", make([]byte, 100)), trak)
+	}
+
+	if !faststart {
+		return bytes.Join([][]byte{ftyp, mdat, moov(uint64(len(ftyp) + 8))}, nil)
+	}
+
+	offset := uint64(len(ftyp) + len(moov(0)) + 8)
+	return bytes.Join([][]byte{ftyp, moov(offset), mdat}, nil)
+}
+
+// chunk returns the bytes of the file pointed by its first chunk offset
+func chunk(t *testing.T, file []byte) []byte {
+	for _, kind := range []string{"stco", "co64"} {
+		i := bytes.Index(file, []byte(kind))
+		if i < 0 {
+			continue
+		}
+
+		offset := uint64(binary.BigEndian.Uint32(file[i+12:]))
+		if kind == "co64" {
+			offset = binary.BigEndian.Uint64(file[i+12:])
+		}
+
+		if offset+uint64(len(audio)) > uint64(len(file)) {
+			t.Fatalf("chunk offset %d out of the file", offset)
+		}
+
+		return file[offset : offset+uint64(len(audio))]
+	}
+
+	t.Fatalf("no chunk offset table")
+	return nil
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "track.m4a")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return path
+}
+
+func TestEmbed(t *testing.T) {
+	cover := &artwork.Image{Data: []byte("jpeg data"), Format: "jpeg"}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"stco, moov first", movie("stco", true)},
+		{"co64, moov first", movie("co64", true)},
+		{"stco, mdat first", movie("stco", false)},
+	}
+
+	for _, test := range tests {
+		path := writeFile(t, test.data)
+
+		if err := mp4art.Embed(path, cover, false); err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+
+		data, mimeType, err := mp4art.ReadCover(path)
+		if err != nil || !bytes.Equal(data, cover.Data) || mimeType != "image/jpeg" {
+			t.Errorf("%s: unexpected cover %q %s %v", test.name, data, mimeType, err)
+		}
+
+		file, _ := ioutil.ReadFile(path)
+		if !bytes.Equal(chunk(t, file), audio) {
+			t.Errorf("%s: the chunk offset does not point to the audio data", test.name)
+		}
+
+		if !bytes.Contains(file, []byte("mdirappl")) {
+			t.Errorf("%s: the metadata handler was not added", test.name)
+		}
+
+		if err := mp4art.Embed(path, cover, false); !errors.Is(err, mp4art.ErrCoverExists) {
+			t.Errorf("%s: expected the existing cover to be kept, got %v", test.name, err)
+		}
+	}
+}
+
+func TestEmbedInterleaved(t *testing.T) {
+	// The media data is split around the moov atom, with a chunk in each mdat
+	second := bytes.Repeat([]byte("ALAC frame"), 64)
+	ftyp := box("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42isom"))
+	moov := func(offsets ...uint64) []byte {
+		stbl := box("stbl", box("stsd", make([]byte, 8)), chunkOffsets("stco", offsets...))
+		return box("moov", box("mvhd", make([]byte, 100)), box("trak", box("mdia", box("minf", stbl))))
+	}
+
+	first := uint64(len(ftyp) + 8)
+	last := first + uint64(len(audio)) + uint64(len(moov(0, 0))) + 8
+	path := writeFile(t, bytes.Join([][]byte{ftyp, box("mdat", audio), moov(first, last), box("mdat", second)}, nil))
+
+	if err := mp4art.Embed(path, &artwork.Image{Data: []byte("jpeg data"), Format: "jpeg"}, false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	file, _ := ioutil.ReadFile(path)
+	i := bytes.Index(file, []byte("stco"))
+	for n, expected := range [][]byte{audio, second} {
+		offset := binary.BigEndian.Uint32(file[i+12+4*n:])
+		if int(offset)+len(expected) > len(file) || !bytes.Equal(file[offset:int(offset)+len(expected)], expected) {
+			t.Errorf("chunk %d: the offset %d does not point to its audio data", n, offset)
+		}
+	}
+}
+
+func TestEmbedChunkOffsets(t *testing.T) {
+	cover := &artwork.Image{Data: []byte("jpeg data"), Format: "jpeg"}
+
+	// The chunk offsets are past the end of the small test files, only the
+	// offsets tables are checked
+	file := func(kind string, offset uint64) []byte {
+		stbl := box("stbl", box("stsd", make([]byte, 8)), chunkOffsets(kind, offset))
+		moov := box("moov", box("mvhd", make([]byte, 100)), box("trak", box("mdia", box("minf", stbl))))
+		return bytes.Join([][]byte{box("ftyp", []byte("M4A ")), moov, box("mdat", audio)}, nil)
+	}
+
+	// The 64 bits offsets are shifted beyond 4 GiB
+	original := file("co64", 1<<33)
+	path := writeFile(t, original)
+	if err := mp4art.Embed(path, cover, false); err != nil {
+		t.Fatalf("co64: unexpected error %v", err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	i := bytes.Index(data, []byte("co64"))
+	if offset := binary.BigEndian.Uint64(data[i+12:]); offset != 1<<33+uint64(len(data)-len(original)) {
+		t.Errorf("co64: unexpected chunk offset %d", offset)
+	}
+
+	// The 32 bits offsets which would overflow fail, the file is untouched
+	original = file("stco", math.MaxUint32-8)
+	path = writeFile(t, original)
+	if err := mp4art.Embed(path, cover, false); err == nil {
+		t.Errorf("stco: expected an overflow error")
+	}
+
+	if data, _ := ioutil.ReadFile(path); !bytes.Equal(data, original) {
+		t.Errorf("stco: expected the file to be untouched")
+	}
+}
+
+func TestEmbedReplace(t *testing.T) {
+	path := writeFile(t, movie("stco", true))
+
+	mp4art.Embed(path, &artwork.Image{Data: bytes.Repeat([]byte("first"), 100), Format: "jpeg"}, false)
+	if err := mp4art.Embed(path, &artwork.Image{Data: []byte("second"), Format: "png"}, true); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	data, mimeType, _ := mp4art.ReadCover(path)
+	if string(data) != "second" || mimeType != "image/png" {
+		t.Errorf("expected the cover to be replaced, got %q %s", data, mimeType)
+	}
+
+	// The moov atom shrank, the chunk offset must follow
+	file, _ := ioutil.ReadFile(path)
+	if !bytes.Equal(chunk(t, file), audio) || bytes.Count(file, []byte("covr")) != 1 {
+		t.Errorf("expected a single cover and the audio data to be preserved")
+	}
+
+	if ok, err := mp4art.HasCover(path); !ok || err != nil {
+		t.Errorf("expected the file to have a cover, got %v", err)
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	tests := [][]byte{
+		[]byte("not an mp4 file"),
+		box("ftyp", []byte("M4A ")),
+	}
+
+	for _, data := range tests {
+		path := writeFile(t, data)
+
+		if _, err := mp4art.HasCover(path); !errors.Is(err, mp4art.ErrInvalidFile) {
+			t.Errorf("expected an invalid file error for %q, got %v", data, err)
+		}
+	}
+}
+
+func TestReadTags(t *testing.T) {
+	text := func(kind string, value string) []byte {
+		return box(kind, box("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(value)))
+	}
+
+	ilst := box("ilst", text("\xa9alb", "Halcyon"), text("aART", "Ellie Goulding"), text("\xa9nam", "Anything Could Happen"))
+	meta := box("meta", make([]byte, 4), box("hdlr", make([]byte, 25)), ilst)
+	moov := box("moov", box("mvhd", make([]byte, 100)), box("udta", meta))
+	path := writeFile(t, append(box("ftyp", []byte("M4A ")), moov...))
+
+	expected := artwork.Tags{Album: "Halcyon", AlbumArtist: "Ellie Goulding", Title: "Anything Could Happen"}
+	if tags, err := mp4art.ReadTags(path); err != nil || *tags != expected {
+		t.Errorf("unexpected tags %+v %v", tags, err)
+	}
+
+	// The tags are preserved by Embed
+	mp4art.Embed(path, &artwork.Image{Data: []byte("jpeg data"), Format: "jpeg"}, false)
+	if tags, err := mp4art.ReadTags(path); err != nil || *tags != expected {
+		t.Errorf("unexpected tags after embedding %+v %v", tags, err)
+	}
+
+	if tags, err := mp4art.ReadTags(writeFile(t, movie("stco", true))); err != nil || !tags.Empty() {
+		t.Errorf("expected empty tags, got %+v %v", tags, err)
+	}
+}
@@ -0,0 +1,121 @@
+// Package oggart provides few helper methods to read the tags of Ogg Vorbis
+// and Opus files, so that their artworks can be looked up by the coverart
+// packages
+package oggart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/piraveen/go-coverart/artwork"
+)
+
+// Error values returned by the helper methods
+var (
+	// ErrInvalidFile is returned for the files which are not valid Ogg files
+	ErrInvalidFile = errors.New("Invalid Ogg file")
+
+	// ErrUnsupportedCodec is returned for the Ogg streams which are neither
+	// Vorbis nor Opus
+	ErrUnsupportedCodec = errors.New("Unsupported Ogg codec")
+)
+
+// Signatures of the comment headers, which precede the Vorbis comment
+var (
+	vorbisComment = []byte("\x03vorbis")
+	opusComment   = []byte("OpusTags")
+)
+
+// Used to read the packets of the first logical stream of the file, the
+// packets are split across the segments of the pages
+type reader struct {
+	r       *bufio.Reader
+	serial  []byte
+	packet  []byte
+	packets [][]byte
+}
+
+// Used to read the next page and append its complete packets
+func (r *reader) readPage() error {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r.r, header); err != nil || string(header[:4]) != "OggS" {
+		return ErrInvalidFile
+	}
+
+	lacing := make([]byte, header[26])
+	if _, err := io.ReadFull(r.r, lacing); err != nil {
+		return ErrInvalidFile
+	}
+
+	size := 0
+	for _, n := range lacing {
+		size += int(n)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return ErrInvalidFile
+	}
+
+	if r.serial == nil {
+		r.serial = header[14:18]
+	} else if !bytes.Equal(r.serial, header[14:18]) {
+		return nil
+	}
+
+	// A packet ends with the first segment shorter than 255 bytes
+	for _, n := range lacing {
+		r.packet = append(r.packet, body[:n]...)
+		body = body[n:]
+
+		if n < 255 {
+			r.packets = append(r.packets, r.packet)
+			r.packet = nil
+		}
+	}
+
+	return nil
+}
+
+// Used to read the first n packets of the stream
+func (r *reader) readPackets(n int) ([][]byte, error) {
+	for len(r.packets) < n {
+		if err := r.readPage(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.packets[:n], nil
+}
+
+// ReadTags returns the album, artists and title of the Vorbis comment of the
+// Ogg Vorbis or Opus file
+func ReadTags(path string) (*artwork.Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	r := &reader{r: bufio.NewReader(f)}
+
+	// The identification header is followed by the comment header
+	packets, err := r.readPackets(2)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := packets[1]
+	switch {
+	case bytes.HasPrefix(comment, vorbisComment):
+		return artwork.ParseVorbisComment(comment[len(vorbisComment):])
+	case bytes.HasPrefix(comment, opusComment):
+		return artwork.ParseVorbisComment(comment[len(opusComment):])
+	}
+
+	return nil, ErrUnsupportedCodec
+}
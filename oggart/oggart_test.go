@@ -0,0 +1,120 @@
+package oggart_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/piraveen/go-coverart"
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/oggart"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// vorbisComment builds a Vorbis comment holding the fields
+func vorbisComment(fields ...string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, 6)
+	data = append(data, "vendor"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(fields)))
+
+	for _, f := range fields {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(f)))
+		data = append(data, f...)
+	}
+
+	return data
+}
+
+// page builds an Ogg page of the stream holding the packets, the last one is
+// continued on the next page when open is true
+func page(serial uint32, open bool, packets ...[]byte) []byte {
+	lacing := []byte{}
+	body := []byte{}
+
+	for i, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+
+		if !open || i < len(packets)-1 {
+			lacing = append(lacing, byte(n))
+		}
+
+		body = append(body, p...)
+	}
+
+	header := append([]byte("OggS"), make([]byte, 23)...)
+	binary.LittleEndian.PutUint32(header[14:], serial)
+	header[26] = byte(len(lacing))
+
+	return append(append(header, lacing...), body...)
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "track.ogg")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return path
+}
+
+func TestReadTags(t *testing.T) {
+	comment := vorbisComment("ALBUM=Halcyon", "ARTIST=Ellie Goulding", "TITLE=Anything Could Happen")
+	expected := artwork.Tags{Album: "Halcyon", Artist: "Ellie Goulding", Title: "Anything Could Happen"}
+
+	// The comment header is large enough to span two pages, interleaved with
+	// the page of another stream
+	vorbis := append(append([]byte("\x03vorbis"), comment...), 1)
+	vorbis = append(vorbis, make([]byte, 600)...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"vorbis", bytes.Join([][]byte{
+			page(1, false, []byte("\x01vorbis")),
+			page(1, true, vorbis[:510]),
+			page(2, false, []byte("OpusHead")),
+			page(1, false, vorbis[510:]),
+		}, nil)},
+		{"opus", bytes.Join([][]byte{
+			page(1, false, []byte("OpusHead")),
+			page(1, false, append([]byte("OpusTags"), comment...)),
+		}, nil)},
+	}
+
+	for _, test := range tests {
+		tags, err := oggart.ReadTags(writeFile(t, test.data))
+		if err != nil || *tags != expected {
+			t.Errorf("%s: unexpected tags %+v %v", test.name, tags, err)
+		}
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	if _, err := oggart.ReadTags(writeFile(t, []byte("not an ogg file"))); !errors.Is(err, oggart.ErrInvalidFile) {
+		t.Errorf("expected an invalid file error, got %v", err)
+	}
+
+	data := append(page(1, false, []byte("\x80theora")), page(1, false, []byte("\x81theora"))...)
+	if _, err := oggart.ReadTags(writeFile(t, data)); !errors.Is(err, oggart.ErrUnsupportedCodec) {
+		t.Errorf("expected an unsupported codec error, got %v", err)
+	}
+}
+
+func ExampleReadTags() {
+	tags, err := oggart.ReadTags("track.ogg")
+	if err != nil {
+		return
+	}
+
+	result, err := coverart.Itunes().Album(context.Background(), tags.Album, tags.Artist)
+	if err == nil {
+		fmt.Println(result.URL())
+	}
+}
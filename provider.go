@@ -0,0 +1,247 @@
+package coverart
+
+import (
+	"context"
+
+	"github.com/piraveen/go-coverart/artwork"
+	"github.com/piraveen/go-coverart/itunesart"
+	"github.com/piraveen/go-coverart/lastfmart"
+	"github.com/piraveen/go-coverart/spotifyart"
+)
+
+// errNoLookup is returned when neither the Context nor the plain method of a
+// lookup is set on the helper. It matches ErrNotFound, so that a Chain moves on
+// to its next provider.
+var errNoLookup = artwork.NotFound("The provider has no method for this lookup")
+
+// Names of the providers, as returned by Provider.Name
+const (
+	ItunesName  = artwork.ItunesName
+	LastFmName  = artwork.LastFmName
+	SpotifyName = artwork.SpotifyName
+)
+
+// The Provider represents any service able to look up album, track or artist
+// artworks. ItunesArt, LastFmArt and SpotifyArt all implement it, so they can
+// be used interchangeably. Every lookup is bound to the given context.
+type Provider interface {
+	Name() string
+	Album(ctx context.Context, album string, artist string) (Result, error)
+	Track(ctx context.Context, track string, artist string) (Result, error)
+	Artist(ctx context.Context, artist string) (Result, error)
+}
+
+// The Result represents the artwork urls returned by a Provider, normalized
+// to the same set of sizes whatever the service it comes from
+type Result struct {
+	Provider string `json:"provider"`
+	Small    string `json:"small,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Large    string `json:"large,omitempty"`
+	Default  string `json:"default,omitempty"`
+
+	// Confidence is the score of the match, from 0 to 1, when it was
+	// verified by a Matcher. It is zero for the unverified results.
+	Confidence float64 `json:"confidence,omitempty"`
+
+	// Artwork holds every image of the service with its dimensions, when
+	// they are known, and the match they belong to. It is nil for the results
+	// which were not returned by a service helper.
+	Artwork *artwork.Artwork `json:"artwork,omitempty"`
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func fromItunes(res itunesart.Result) Result {
+	art := res.Artwork()
+	return Result{
+		Provider: ItunesName,
+		Small:    firstOf(res.Small, res.Tiny),
+		Medium:   res.Medium,
+		Large:    res.Large,
+		Default:  res.Default,
+		Artwork:  &art,
+	}
+}
+
+func fromLastFm(res lastfmart.Result) Result {
+	art := res.Artwork()
+	return Result{
+		Provider: LastFmName,
+		Small:    res.Small,
+		Medium:   res.Medium,
+		Large:    firstOf(res.Mega, res.ExtraLarge, res.Large),
+		Default:  res.Default,
+		Artwork:  &art,
+	}
+}
+
+func fromSpotify(res spotifyart.Result) Result {
+	art := res.Artwork()
+	return Result{
+		Provider: SpotifyName,
+		Small:    res.Small,
+		Medium:   res.Medium,
+		Large:    res.Large,
+		Default:  res.Default,
+		Artwork:  &art,
+	}
+}
+
+// Name returns the name of the Itunes provider
+func (p ItunesArt) Name() string {
+	return ItunesName
+}
+
+// Album gets the album artwork from Itunes as a Result
+func (p ItunesArt) Album(ctx context.Context, album string, artist string) (Result, error) {
+	res, err := lookup[itunesart.Result](ctx, p.AlbumCoverContext, p.AlbumCover, album, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromItunes(res), nil
+}
+
+// Track gets the track artwork from Itunes as a Result
+func (p ItunesArt) Track(ctx context.Context, track string, artist string) (Result, error) {
+	res, err := lookup[itunesart.Result](ctx, p.TrackCoverContext, p.TrackCover, track, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromItunes(res), nil
+}
+
+// Artist gets the artist artwork from Itunes as a Result, the artwork of one
+// of the albums of the artist
+func (p ItunesArt) Artist(ctx context.Context, artist string) (Result, error) {
+	res, err := lookup[itunesart.Result](ctx, p.ArtistCoverContext, p.ArtistCover, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromItunes(res), nil
+}
+
+// Name returns the name of the Last.fm provider
+func (p LastFmArt) Name() string {
+	return LastFmName
+}
+
+// Album gets the album artwork from Last.fm as a Result
+func (p LastFmArt) Album(ctx context.Context, album string, artist string) (Result, error) {
+	res, err := lookup[lastfmart.Result](ctx, p.AlbumCoverContext, p.AlbumCover, album, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromLastFm(res), nil
+}
+
+// Track gets the track artwork from Last.fm as a Result
+func (p LastFmArt) Track(ctx context.Context, track string, artist string) (Result, error) {
+	res, err := lookup[lastfmart.Result](ctx, p.TrackCoverContext, p.TrackCover, track, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromLastFm(res), nil
+}
+
+// Artist gets the artist artwork from Last.fm as a Result
+func (p LastFmArt) Artist(ctx context.Context, artist string) (Result, error) {
+	res, err := lookup[lastfmart.Result](ctx, p.ArtistCoverContext, p.ArtistCover, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromLastFm(res), nil
+}
+
+// Name returns the name of the Spotify provider
+func (p SpotifyArt) Name() string {
+	return SpotifyName
+}
+
+// Album gets the album artwork from Spotify as a Result
+func (p SpotifyArt) Album(ctx context.Context, album string, artist string) (Result, error) {
+	res, err := lookup[spotifyart.Result](ctx, p.AlbumCoverContext, p.AlbumCover, album, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromSpotify(res), nil
+}
+
+// Track gets the track artwork from Spotify as a Result
+func (p SpotifyArt) Track(ctx context.Context, track string, artist string) (Result, error) {
+	res, err := lookup[spotifyart.Result](ctx, p.TrackCoverContext, p.TrackCover, track, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromSpotify(res), nil
+}
+
+// Artist gets the artist artwork from Spotify as a Result
+func (p SpotifyArt) Artist(ctx context.Context, artist string) (Result, error) {
+	res, err := lookup[spotifyart.Result](ctx, p.ArtistCoverContext, p.ArtistCover, artist)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return fromSpotify(res), nil
+}
+
+// Used to run a lookup of a helper with its Context method, or with its plain
+// method when it is not set, once the context is checked. The methods are the
+// fields of the helper, given the name and the artist of the query, or the
+// artist alone, as args.
+func lookup[T any](ctx context.Context, withContext any, plain any, args ...string) (T, error) {
+	var zero T
+
+	switch fn := withContext.(type) {
+	case func(context.Context, string, string) (T, error):
+		if fn != nil {
+			return fn(ctx, args[0], args[1])
+		}
+	case func(context.Context, string) (T, error):
+		if fn != nil {
+			return fn(ctx, args[0])
+		}
+	case func(context.Context, string, ...string) (T, error):
+		if fn != nil {
+			return fn(ctx, args[0], args[1:]...)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	switch fn := plain.(type) {
+	case func(string, string) (T, error):
+		if fn != nil {
+			return fn(args[0], args[1])
+		}
+	case func(string) (T, error):
+		if fn != nil {
+			return fn(args[0])
+		}
+	case func(string, ...string) (T, error):
+		if fn != nil {
+			return fn(args[0], args[1:]...)
+		}
+	}
+
+	return zero, errNoLookup
+}
@@ -0,0 +1,360 @@
+package coverart
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/piraveen/go-coverart/itunesart"
+	"github.com/piraveen/go-coverart/lastfmart"
+	"github.com/piraveen/go-coverart/spotifyart"
+)
+
+// errNoSearcher is returned when the provider can not list candidates
+var errNoSearcher = errors.New("No provider supports candidate searches")
+
+// The unsearchedError represents a search of a Chain which found no candidate
+// while some of its providers could not list candidates. It matches
+// errNoSearcher, so that the Matcher falls back to the lookup of these
+// providers, and the error of the last searching provider.
+type unsearchedError struct {
+	chain *Chain
+	err   error
+}
+
+func (e *unsearchedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unsearchedError) Unwrap() []error {
+	return []error{errNoSearcher, e.err}
+}
+
+// The Candidate represents one of the matches of a search, with the metadata
+// needed to pick the right release and its artworks. The fields unknown to the
+// service are empty.
+type Candidate struct {
+	Provider    string `json:"provider"`
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+
+	// Score is the confidence that the candidate matches the query, only set
+	// by a Matcher
+	Score  float64 `json:"score,omitempty"`
+	Result Result  `json:"result"`
+}
+
+// The Searcher represents any Provider able to list several matches of a
+// lookup, in the order of relevance of the service. ItunesArt, LastFmArt,
+// SpotifyArt, Chain and Matcher all implement it. The default number of
+// candidates of the service is requested when limit is zero.
+type Searcher interface {
+	AlbumCandidates(ctx context.Context, album string, artist string, limit int) ([]Candidate, error)
+	TrackCandidates(ctx context.Context, track string, artist string, limit int) ([]Candidate, error)
+	ArtistCandidates(ctx context.Context, artist string, limit int) ([]Candidate, error)
+}
+
+// The Verifier represents any Searcher whose candidates carry artworks which
+// are not checked, to avoid a request per candidate. Verify checks the
+// artworks of the chosen candidate only, and drops the missing ones. ItunesArt,
+// Chain and Matcher all implement it, the Matcher verifies its best match.
+type Verifier interface {
+	Verify(ctx context.Context, res Result) Result
+}
+
+func fromItunesCandidates(candidates []itunesart.Candidate) []Candidate {
+	res := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		res[i] = Candidate{
+			Provider:    ItunesName,
+			ID:          strconv.FormatInt(c.ID, 10),
+			Name:        c.Name,
+			Artist:      c.Artist,
+			Album:       c.Album,
+			ReleaseDate: c.ReleaseDate,
+			Result:      fromItunes(c.Result),
+		}
+
+		art := c.Artwork()
+		res[i].Result.Artwork = &art
+	}
+
+	return res
+}
+
+func fromLastFmCandidates(candidates []lastfmart.Candidate, album bool) []Candidate {
+	res := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		res[i] = Candidate{
+			Provider: LastFmName,
+			ID:       c.MBID,
+			Name:     c.Name,
+			Artist:   c.Artist,
+			Result:   fromLastFm(c.Result),
+		}
+
+		if album {
+			res[i].Album = c.Name
+		}
+
+		art := c.Artwork()
+		res[i].Result.Artwork = &art
+	}
+
+	return res
+}
+
+func fromSpotifyCandidates(candidates []spotifyart.Candidate) []Candidate {
+	res := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		res[i] = Candidate{
+			Provider:    SpotifyName,
+			ID:          c.ID,
+			Name:        c.Name,
+			Artist:      strings.Join(c.Artists, ", "),
+			Album:       c.Album,
+			ReleaseDate: c.ReleaseDate,
+			Result:      fromSpotify(c.Result),
+		}
+
+		art := c.Artwork()
+		res[i].Result.Artwork = &art
+	}
+
+	return res
+}
+
+// AlbumCandidates gets the albums matching the query from Itunes
+func (p ItunesArt) AlbumCandidates(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	if p.AlbumCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.AlbumCandidatesContext(ctx, album, artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromItunesCandidates(res), nil
+}
+
+// TrackCandidates gets the tracks matching the query from Itunes
+func (p ItunesArt) TrackCandidates(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	if p.TrackCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.TrackCandidatesContext(ctx, track, artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromItunesCandidates(res), nil
+}
+
+// ArtistCandidates can not list the artists of Itunes, which have no artwork
+// of their own. The Matcher and the Chain use the Artist lookup instead.
+func (p ItunesArt) ArtistCandidates(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	return nil, errNoSearcher
+}
+
+// Verify checks the Large artwork of an Itunes result, rewritten to the
+// ArtworkSize of the client, see itunesart.Client.Verify. The results of the
+// other providers are returned as is.
+func (p ItunesArt) Verify(ctx context.Context, res Result) Result {
+	if res.Provider != ItunesName || p.VerifyContext == nil {
+		return res
+	}
+
+	verified := fromItunes(p.VerifyContext(ctx, itunesart.Result{
+		Small:   res.Small,
+		Medium:  res.Medium,
+		Large:   res.Large,
+		Default: res.Default,
+	}))
+	verified.Confidence = res.Confidence
+	if res.Artwork != nil {
+		verified.Artwork.ID, verified.Artwork.Name, verified.Artwork.Artist = res.Artwork.ID, res.Artwork.Name, res.Artwork.Artist
+	}
+
+	return verified
+}
+
+// AlbumCandidates gets the albums matching the query from Last.fm
+func (p LastFmArt) AlbumCandidates(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	if p.AlbumCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.AlbumCandidatesContext(ctx, album, artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromLastFmCandidates(res, true), nil
+}
+
+// TrackCandidates gets the tracks matching the query from Last.fm
+func (p LastFmArt) TrackCandidates(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	if p.TrackCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.TrackCandidatesContext(ctx, track, artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromLastFmCandidates(res, false), nil
+}
+
+// ArtistCandidates gets the artists matching the query from Last.fm
+func (p LastFmArt) ArtistCandidates(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	if p.ArtistCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.ArtistCandidatesContext(ctx, artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromLastFmCandidates(res, false), nil
+}
+
+// AlbumCandidates gets the albums matching the query from Spotify
+func (p SpotifyArt) AlbumCandidates(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	if p.AlbumCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.AlbumCandidatesContext(ctx, album, limit, artist)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromSpotifyCandidates(res), nil
+}
+
+// TrackCandidates gets the tracks matching the query from Spotify
+func (p SpotifyArt) TrackCandidates(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	if p.TrackCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.TrackCandidatesContext(ctx, track, limit, artist)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromSpotifyCandidates(res), nil
+}
+
+// ArtistCandidates gets the artists matching the query from Spotify
+func (p SpotifyArt) ArtistCandidates(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	if p.ArtistCandidatesContext == nil {
+		return nil, errNoSearcher
+	}
+
+	res, err := p.ArtistCandidatesContext(ctx, artist, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromSpotifyCandidates(res), nil
+}
+
+// AlbumCandidates gets the albums matching the query from every provider of
+// the chain, see Chain.search
+func (c *Chain) AlbumCandidates(ctx context.Context, album string, artist string, limit int) ([]Candidate, error) {
+	return c.search(ctx, func(s Searcher) ([]Candidate, error) {
+		return s.AlbumCandidates(ctx, album, artist, limit)
+	})
+}
+
+// TrackCandidates gets the tracks matching the query from every provider of
+// the chain, see Chain.search
+func (c *Chain) TrackCandidates(ctx context.Context, track string, artist string, limit int) ([]Candidate, error) {
+	return c.search(ctx, func(s Searcher) ([]Candidate, error) {
+		return s.TrackCandidates(ctx, track, artist, limit)
+	})
+}
+
+// ArtistCandidates gets the artists matching the query from every provider of
+// the chain, see Chain.search
+func (c *Chain) ArtistCandidates(ctx context.Context, artist string, limit int) ([]Candidate, error) {
+	return c.search(ctx, func(s Searcher) ([]Candidate, error) {
+		return s.ArtistCandidates(ctx, artist, limit)
+	})
+}
+
+// Verify checks the artworks of the result with the provider of the chain it
+// comes from, when that provider implements Verifier
+func (c *Chain) Verify(ctx context.Context, res Result) Result {
+	for _, p := range c.Providers {
+		if v, ok := p.(Verifier); ok && p.Name() == res.Provider {
+			return v.Verify(ctx, res)
+		}
+	}
+
+	return res
+}
+
+// Runs the search on every provider implementing Searcher, in order, and
+// returns the candidates of all of them. A failing provider is skipped when
+// its fallback rule allows it, the candidates are returned grouped by
+// provider. When none is found, the providers which could not search are
+// returned in an unsearchedError, to be looked up in order.
+func (c *Chain) search(ctx context.Context, fn func(Searcher) ([]Candidate, error)) ([]Candidate, error) {
+	candidates := []Candidate{}
+	unsearched := []Provider{}
+	err := errNoSearcher
+
+	for _, p := range c.Providers {
+		s, ok := p.(Searcher)
+		if !ok {
+			unsearched = append(unsearched, p)
+			continue
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		found, searchErr := fn(s)
+		if searchErr == nil {
+			candidates = append(candidates, found...)
+			continue
+		}
+
+		if errors.Is(searchErr, errNoSearcher) {
+			unsearched = append(unsearched, p)
+			continue
+		}
+
+		fallback, ok := c.Fallback[p.Name()]
+		if !ok || fallback == nil {
+			fallback = DefaultFallback
+		}
+
+		if !fallback(searchErr) {
+			return nil, searchErr
+		}
+
+		err = searchErr
+	}
+
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	if len(unsearched) > 0 && err != errNoSearcher {
+		return nil, &unsearchedError{chain: &Chain{Providers: unsearched, Fallback: c.Fallback}, err: err}
+	}
+
+	return nil, err
+}
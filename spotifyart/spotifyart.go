@@ -4,23 +4,43 @@ package spotifyart
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/piraveen/go-coverart/artwork"
 )
 
-var clId, clSecret string
+// DefaultBaseURL is the base url of the Spotify Web API
+const DefaultBaseURL = "https://api.spotify.com/v1"
+
+// DefaultTokenURL is the url of the Spotify Accounts service used to request
+// access tokens
+const DefaultTokenURL = "https://accounts.spotify.com/api/token"
 
-const apiUrl = "https://api.spotify.com/v1"
-const apiUrlTrack = apiUrl + "/search?type=track&limit=1&q="
-const apiUrlAlbum = apiUrl + "/search?type=album&limit=1&q="
-const apiUrlArtist = apiUrl + "/search?type=artist&limit=1&q="
-const apiUrlToken = "https://accounts.spotify.com/api/token"
+// Access tokens are refreshed this long before they expire, so that they do not
+// expire during a request. The margin is halved for the tokens living less
+// than twice as long.
+const tokenRefreshMargin = time.Minute
+
+const apiUrlTrack = "/search?type=track&limit="
+const apiUrlAlbum = "/search?type=album&limit="
+const apiUrlArtist = "/search?type=artist&limit="
+
+// Number of candidates requested by the candidate searches, by default and at
+// most
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+)
 
 // The Result represents the specific size of artworks and contains the url of
 // each size of artwork returned by the Spotify API
@@ -29,43 +49,97 @@ type Result struct {
 	Medium  string
 	Small   string
 	Default string
+
+	// Images are all the images returned by the Spotify API, with their
+	// dimensions when known, from the largest to the smallest
+	Images []artwork.Source
+}
+
+// Artwork converts the result to the Artwork shared by the service packages,
+// with the dimensions returned by the Spotify API
+func (r Result) Artwork() artwork.Artwork {
+	a := artwork.Artwork{Provider: artwork.SpotifyName, Retrieved: time.Now()}
+	for i := len(r.Images) - 1; i >= 0; i-- {
+		a.Add(r.Images[i])
+	}
+
+	a.Add(artwork.Source{URL: r.Small})
+	a.Add(artwork.Source{URL: r.Medium})
+	a.Add(artwork.Source{URL: r.Large})
+	a.Add(artwork.Source{URL: r.Default})
+
+	return a
+}
+
+// Artwork converts the candidate to the Artwork shared by the service
+// packages, with the id, name and artists of the match
+func (c Candidate) Artwork() artwork.Artwork {
+	a := c.Result.Artwork()
+	a.ID, a.Name, a.Artist = c.ID, c.Name, strings.Join(c.Artists, ", ")
+
+	return a
 }
 
 type image struct {
-	Width  *int   `json:width`
-	Height *int   `json:height`
-	Url    string `json:url`
+	Width  *int   `json:"width"`
+	Height *int   `json:"height"`
+	Url    string `json:"url"`
+}
+
+// The Candidate represents one of the matches of a search, with its metadata
+// and artworks
+type Candidate struct {
+	// ID is the Spotify id of the album, artist or track
+	ID          string
+	Name        string
+	Artists     []string
+	Album       string
+	AlbumType   string
+	ReleaseDate string
+	TrackCount  int
+	Genres      []string
+	Result      Result
+}
+
+type artistRef struct {
+	Name string `json:"name"`
 }
 
 type item struct {
-	Type   string  `json:type`
-	Name   string  `json:name`
-	Images []image `json:images`
-	Album  *item   `json:album`
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	Name        string      `json:"name"`
+	Images      []image     `json:"images"`
+	Album       *item       `json:"album"`
+	Artists     []artistRef `json:"artists"`
+	AlbumType   string      `json:"album_type"`
+	ReleaseDate string      `json:"release_date"`
+	TotalTracks int         `json:"total_tracks"`
+	Genres      []string    `json:"genres"`
 }
 
 type items struct {
-	Items []item `json:items`
+	Items []item `json:"items"`
 }
 
 type httpSearch struct {
-	Albums  *items `json:albums`
-	Tracks  *items `json:tracks`
-	Artists *items `json:artists`
+	Albums  *items `json:"albums"`
+	Tracks  *items `json:"tracks"`
+	Artists *items `json:"artists"`
 }
 
 type httpErrorDetails struct {
-	Status  int    `json:status`
-	Message string `json:message`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
 }
 
 type httpError struct {
-	Error *httpErrorDetails `json:error`
+	Error *httpErrorDetails `json:"error"`
 }
 
 type httpTokenError struct {
-	Error       *string `json:error`
-	Description *string `json:"errorerror_description,omitempty"`
+	Error       *string `json:"error"`
+	Description *string `json:"error_description,omitempty"`
 }
 
 type httpToken struct {
@@ -74,19 +148,72 @@ type httpToken struct {
 	ExpiresIn   int    `json:"expires_in"`
 }
 
+// The Client represents a Spotify API client. Each Client owns its Client Id,
+// Client Secret, access token and http client, so several independently
+// configured clients can be used in the same process.
+type Client struct {
+	clId     string
+	clSecret string
+
+	// HTTPClient is used to execute the requests, http.DefaultClient is
+	// used when nil
+	HTTPClient *http.Client
+
+	// Retry is the policy applied to the requests failing with a transient
+	// error, like a rate limit or a server error
+	Retry artwork.RetryPolicy
+
+	// BaseURL is the url the API paths are appended to
+	BaseURL string
+
+	// TokenURL is the url used to request access tokens
+	TokenURL string
+
+	mu    sync.Mutex
+	token string
+
+	// refresh is the time the token is about to expire, zero when unknown
+	refresh time.Time
+
+	// refreshMu ensures a single access token request at a time
+	refreshMu sync.Mutex
+}
+
+// defaultClient is used by the package level helper methods
+var defaultClient = NewClient("", "")
+
+// NewClient returns a new Client using the given Spotify Client Id and Client
+// Secret. Both are optional, see Client.Configure.
+func NewClient(clientId string, clientSecret string) *Client {
+	return &Client{
+		clId:       clientId,
+		clSecret:   clientSecret,
+		HTTPClient: &http.Client{},
+		Retry:      artwork.DefaultRetryPolicy,
+		BaseURL:    DefaultBaseURL,
+		TokenURL:   DefaultTokenURL,
+	}
+}
+
 // Configure is optional, you can use it to set the Spotify Client Id and
 // Client Secret. This action will result in with a call to the Spotify API
 // to get an access token. The access token will allow you to have a higher
 // limit rate than unauthorized requests
-func Configure(clientId string, clientSecret string) error {
-	clId, clSecret = clientId, clientSecret
-	return GetAccessToken()
+func (c *Client) Configure(clientId string, clientSecret string) error {
+	c.mu.Lock()
+	c.clId, c.clSecret = clientId, clientSecret
+	c.mu.Unlock()
+
+	return c.GetAccessToken()
 }
 
 // CheckCredentials provides a simple method to verify if the Spotify API
 // Credentials have been set
-func CheckCredentials() bool {
-	if len(clId) == 0 || len(clSecret) == 0 {
+func (c *Client) CheckCredentials() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.clId) == 0 || len(c.clSecret) == 0 {
 		return false
 	}
 
@@ -96,50 +223,140 @@ func CheckCredentials() bool {
 // GetAccessToken provides a simple method to verify if the Spotify API
 // Credentials have been set and requests an access token from the Spotify API
 // to increase the requests rate limit. This method can be used to refresh the
-// access token too
-func GetAccessToken() error {
-	if !CheckCredentials() {
-		return errors.New("Invalid Client Id or Client Secret")
+// access token too, however the client refreshes it on its own before it
+// expires.
+func (c *Client) GetAccessToken() error {
+	return c.GetAccessTokenContext(context.Background())
+}
+
+// GetAccessTokenContext is like GetAccessToken, but the request is bound to ctx
+// so it can be cancelled or given a deadline.
+func (c *Client) GetAccessTokenContext(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	return c.refreshToken(ctx)
+}
+
+// Used to request a new access token, refreshMu must be held
+func (c *Client) refreshToken(ctx context.Context) error {
+	if !c.CheckCredentials() {
+		return artwork.Unauthorized("Invalid Client Id or Client Secret")
 	}
 
-	byteCreds := []byte(clId + ":" + clSecret)
+	c.mu.Lock()
+	byteCreds := []byte(c.clId + ":" + c.clSecret)
+	c.mu.Unlock()
+
 	encodedCres := base64.StdEncoding.EncodeToString(byteCreds)
-	return getAccessToken(encodedCres)
+	return c.getAccessToken(ctx, encodedCres)
 }
 
-// Used to get an access token from the Spotify API
-func getAccessToken(ec string) error {
+// Used to get an access token from the Spotify API, the request is retried
+// according to the client policy
+func (c *Client) getAccessToken(ctx context.Context, ec string) error {
+	var resToken *httpToken
 	data := url.Values{"grant_type": {"client_credentials"}}
-	req, _ := http.NewRequest("POST", apiUrlToken, bytes.NewBufferString(data.Encode()))
 
-	req.Header.Add("Authorization", "Basic "+ec)
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	resToken, err := requestToken(req)
+	err := c.Retry.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, bytes.NewBufferString(data.Encode()))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Add("Authorization", "Basic "+ec)
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		resToken, err = c.requestToken(req)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	setToken(resToken.AccessToken)
+	c.setToken(resToken.AccessToken, resToken.ExpiresIn)
 	return nil
 }
 
-// Used to set the access token in the current process environment
-func setToken(t string) {
-	os.Setenv("SPOTIFY_ACCESSTOKEN", t)
+// Used to set the access token of the client, which expires in the given
+// number of seconds. The expiry is unknown when it is zero.
+func (c *Client) setToken(t string, expiresIn int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = t
+	c.refresh = time.Time{}
+	if expiresIn > 0 {
+		lifetime := time.Duration(expiresIn) * time.Second
+		c.refresh = time.Now().Add(lifetime - min(tokenRefreshMargin, lifetime/2))
+	}
+}
+
+// Used to get the access token of the client, if it is set and not about to
+// expire
+func (c *Client) getToken() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.token) == 0 {
+		return "", false
+	}
+
+	if !c.refresh.IsZero() && time.Now().After(c.refresh) {
+		return "", false
+	}
+
+	return c.token, true
+}
+
+// Used to mark the given access token as expired, unless it was already
+// replaced by another request
+func (c *Client) expireToken(t string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == t {
+		c.token = ""
+	}
+}
+
+// Used to get a valid access token, which is requested when missing or about
+// to expire. No token is used by the clients without credentials.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	if token, ok := c.getToken(); ok || !c.CheckCredentials() {
+		return token, nil
+	}
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	// Another request may have refreshed the token in the meantime
+	if token, ok := c.getToken(); ok {
+		return token, nil
+	}
+
+	if err := c.refreshToken(ctx); err != nil {
+		return "", err
+	}
+
+	token, _ := c.getToken()
+	return token, nil
 }
 
-// Used to get the access token in the current process environment
-func getToken() string {
-	return os.Getenv("SPOTIFY_ACCESSTOKEN")
+// Used to get the http client of the client
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
 }
 
-// Executes a manually created request with http.Client service
-func requestToken(req *http.Request) (*httpToken, error) {
+// Executes a manually created request with the http client of the client
+func (c *Client) requestToken(req *http.Request) (*httpToken, error) {
 	resErr := httpTokenError{}
 	resToken := httpToken{}
-	client := &http.Client{}
 
-	resp, err := client.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -153,11 +370,25 @@ func requestToken(req *http.Request) (*httpToken, error) {
 
 	err = json.Unmarshal(body, &resErr)
 	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, artwork.ResponseError(resp, "")
+		}
+
 		return nil, err
 	}
 
 	if resErr.Error != nil {
-		return nil, errors.New(*resErr.Description)
+		message := *resErr.Error
+		if resErr.Description != nil {
+			message = *resErr.Description
+		}
+
+		// The Accounts service answers with a 400 status for invalid credentials
+		if *resErr.Error == "invalid_client" {
+			return nil, artwork.Unauthorized(message)
+		}
+
+		return nil, artwork.ResponseError(resp, message)
 	}
 
 	err = json.Unmarshal(body, &resToken)
@@ -168,6 +399,29 @@ func requestToken(req *http.Request) (*httpToken, error) {
 	return &resToken, nil
 }
 
+// Configure sets the Spotify Client Id and Client Secret of the default client
+// and requests an access token, see Client.Configure
+func Configure(clientId string, clientSecret string) error {
+	return defaultClient.Configure(clientId, clientSecret)
+}
+
+// CheckCredentials verifies if the Spotify API Credentials of the default
+// client have been set
+func CheckCredentials() bool {
+	return defaultClient.CheckCredentials()
+}
+
+// GetAccessToken requests or refreshes the access token of the default client,
+// see Client.GetAccessToken
+func GetAccessToken() error {
+	return defaultClient.GetAccessToken()
+}
+
+// GetAccessTokenContext is like GetAccessToken, but the request is bound to ctx
+func GetAccessTokenContext(ctx context.Context) error {
+	return defaultClient.GetAccessTokenContext(ctx)
+}
+
 // Build all the artwork into size typed object for easy access
 // { Result.SizeName }
 // e.g: Result.Small would return the url for a small size artwork
@@ -176,10 +430,17 @@ func buildResult(sItem item) (Result, error) {
 	sizes := []string{"large", "medium", "small"}
 
 	if len(sItem.Images) == 0 {
-		return res, errors.New("No image was found")
+		return res, artwork.NotFound("No image was found")
 	}
 
 	for key, value := range sItem.Images {
+		src := artwork.Source{URL: value.Url}
+		if value.Width != nil && value.Height != nil {
+			src.Width, src.Height = *value.Width, *value.Height
+		}
+
+		res.Images = append(res.Images, src)
+
 		if key > 2 {
 			res.Default = value.Url
 		} else {
@@ -211,7 +472,7 @@ func parseResults(data []byte, parse string) (Result, error) {
 
 	switch parse {
 	default:
-		return Result{}, errors.New("No image was found")
+		return Result{}, artwork.NotFound("No image was found")
 	case "album":
 		if resp.Albums != nil && len(resp.Albums.Items) > 0 {
 			return buildResult(resp.Albums.Items[0])
@@ -226,20 +487,141 @@ func parseResults(data []byte, parse string) (Result, error) {
 		}
 	}
 
-	return Result{}, errors.New("No image was found")
+	return Result{}, artwork.NotFound("No image was found")
 }
 
-// Executes an http request and returns error or response body
-func request(url string) ([]byte, error) {
+// Parse http response and build the candidates which have an artwork, in the
+// order of the response
+// parse { album, artist, track }
+func parseCandidates(data []byte, parse string) ([]Candidate, error) {
+	resp := httpSearch{}
+
+	err := json.Unmarshal(data, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *items
+	switch parse {
+	case "album":
+		found = resp.Albums
+	case "track":
+		found = resp.Tracks
+	case "artist":
+		found = resp.Artists
+	}
+
+	if found == nil {
+		return nil, artwork.NotFound("No image was found")
+	}
+
+	candidates := []Candidate{}
+
+	for _, it := range found.Items {
+		album := it
+		if parse == "track" {
+			if it.Album == nil {
+				continue
+			}
+
+			album = *it.Album
+		}
+
+		res, err := buildResult(album)
+		if err != nil {
+			continue
+		}
+
+		c := Candidate{
+			ID:          it.ID,
+			Name:        it.Name,
+			AlbumType:   album.AlbumType,
+			ReleaseDate: album.ReleaseDate,
+			TrackCount:  album.TotalTracks,
+			Genres:      it.Genres,
+			Result:      res,
+		}
+
+		if parse != "artist" {
+			c.Album = album.Name
+		}
+
+		for _, a := range it.Artists {
+			c.Artists = append(c.Artists, a.Name)
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	if len(candidates) == 0 {
+		return nil, artwork.NotFound("No image was found")
+	}
+
+	return candidates, nil
+}
+
+// Used to build the url of a search for the value of the field, narrowed with
+// the extras values of the extra field. The limit is bounded to MaxLimit.
+func (c *Client) searchUrl(apiUrl string, limit int, field string, value string, extraField string, extras []string) string {
+	switch {
+	case limit <= 0:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+
+	Url := c.BaseURL + apiUrl + strconv.Itoa(limit) + "&q=" + field + ":" + url.QueryEscape(value+" ")
+	if extra := url.QueryEscape(strings.Join(extras, ",")); len(extra) > 0 {
+		Url += extraField + ":" + extra
+	}
+
+	return Url
+}
+
+// Executes an http request, retried according to the client policy, and
+// returns error or response body. A request rejected because of its access
+// token is retried once with a new one.
+func (c *Client) request(ctx context.Context, url string) ([]byte, error) {
+	var body []byte
+	refreshed := false
+
+	err := c.Retry.Do(ctx, func() error {
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+
+		body, err = c.requestOnce(ctx, url, token)
+		if errors.Is(err, artwork.ErrUnauthorized) && len(token) > 0 && !refreshed {
+			refreshed = true
+			c.expireToken(token)
+
+			if token, err = c.accessToken(ctx); err != nil {
+				return err
+			}
+
+			body, err = c.requestOnce(ctx, url, token)
+		}
+
+		return err
+	})
+
+	return body, err
+}
+
+// Executes a single http request and returns error or response body
+func (c *Client) requestOnce(ctx context.Context, url string, token string) ([]byte, error) {
 	resErr := httpError{}
-	client := &http.Client{}
-	req, _ := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(getToken()) > 0 {
-		req.Header.Add("Authorization", "Bearer "+getToken())
+	if len(token) > 0 {
+		req.Header.Add("Authorization", "Bearer "+token)
 	}
 
-	resp, err := client.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -253,29 +635,36 @@ func request(url string) ([]byte, error) {
 
 	err = json.Unmarshal(body, &resErr)
 	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, artwork.ResponseError(resp, "")
+		}
+
 		return nil, err
 	}
 
 	if resErr.Error != nil {
-		err = errors.New(resErr.Error.Message)
+		return nil, artwork.ResponseError(resp, resErr.Error.Message)
 	}
 
-	return body, err
+	if resp.StatusCode != http.StatusOK {
+		return nil, artwork.ResponseError(resp, "")
+	}
+
+	return body, nil
 }
 
 // AlbumCover gets the album artwork from the Spotify database through out it's
 // dedicated API.
 // Note: artists is optional, but if you specify one, it would give you a more
 // accurate result
-func AlbumCover(album string, artists ...string) (Result, error) {
-	Url := apiUrlAlbum + "album:" + url.QueryEscape(album+" ")
-	extras := url.QueryEscape(strings.Join(artists, ","))
-
-	if len(extras) > 0 {
-		Url += "artist:" + extras
-	}
+func (c *Client) AlbumCover(album string, artists ...string) (Result, error) {
+	return c.AlbumCoverContext(context.Background(), album, artists...)
+}
 
-	data, err := request(Url)
+// AlbumCoverContext is like AlbumCover, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) AlbumCoverContext(ctx context.Context, album string, artists ...string) (Result, error) {
+	data, err := c.request(ctx, c.searchUrl(apiUrlAlbum, 1, "album", album, "artist", artists))
 	if err != nil {
 		return Result{}, err
 	}
@@ -287,15 +676,14 @@ func AlbumCover(album string, artists ...string) (Result, error) {
 // dedicated API.
 // Note: genres is optional, but if you specify at least one, it would give you
 // a more accurate result
-func ArtistCover(artist string, genres ...string) (Result, error) {
-	Url := apiUrlArtist + "artist:" + url.QueryEscape(artist+" ")
-	extras := url.QueryEscape(strings.Join(genres, ","))
-
-	if len(extras) > 0 {
-		Url += "genre:" + extras
-	}
+func (c *Client) ArtistCover(artist string, genres ...string) (Result, error) {
+	return c.ArtistCoverContext(context.Background(), artist, genres...)
+}
 
-	data, err := request(Url)
+// ArtistCoverContext is like ArtistCover, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) ArtistCoverContext(ctx context.Context, artist string, genres ...string) (Result, error) {
+	data, err := c.request(ctx, c.searchUrl(apiUrlArtist, 1, "artist", artist, "genre", genres))
 	if err != nil {
 		return Result{}, err
 	}
@@ -307,18 +695,137 @@ func ArtistCover(artist string, genres ...string) (Result, error) {
 // dedicated API.
 // Note: artists is optional, but if you specify at least one, it would give you
 // a more accurate result
-func TrackCover(track string, artists ...string) (Result, error) {
-	Url := apiUrlTrack + "track:" + url.QueryEscape(track+" ")
-	extras := url.QueryEscape(strings.Join(artists, ","))
-
-	if len(extras) > 0 {
-		Url += "artist:" + extras
-	}
+func (c *Client) TrackCover(track string, artists ...string) (Result, error) {
+	return c.TrackCoverContext(context.Background(), track, artists...)
+}
 
-	data, err := request(Url)
+// TrackCoverContext is like TrackCover, but the request is bound to ctx so it can be
+// cancelled or given a deadline.
+func (c *Client) TrackCoverContext(ctx context.Context, track string, artists ...string) (Result, error) {
+	data, err := c.request(ctx, c.searchUrl(apiUrlTrack, 1, "track", track, "artist", artists))
 	if err != nil {
 		return Result{}, err
 	}
 
 	return parseResults(data, "track")
 }
+
+// AlbumCandidates gets up to limit albums matching the query from the Spotify
+// database, with their metadata and artworks, in the order of relevance of
+// the API. DefaultLimit candidates are requested when limit is zero.
+func (c *Client) AlbumCandidates(album string, limit int, artists ...string) ([]Candidate, error) {
+	return c.AlbumCandidatesContext(context.Background(), album, limit, artists...)
+}
+
+// AlbumCandidatesContext is like AlbumCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) AlbumCandidatesContext(ctx context.Context, album string, limit int, artists ...string) ([]Candidate, error) {
+	data, err := c.request(ctx, c.searchUrl(apiUrlAlbum, limit, "album", album, "artist", artists))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCandidates(data, "album")
+}
+
+// ArtistCandidates gets up to limit artists matching the query from the Spotify
+// database, with their metadata and artworks, in the order of relevance of
+// the API. DefaultLimit candidates are requested when limit is zero.
+func (c *Client) ArtistCandidates(artist string, limit int, genres ...string) ([]Candidate, error) {
+	return c.ArtistCandidatesContext(context.Background(), artist, limit, genres...)
+}
+
+// ArtistCandidatesContext is like ArtistCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) ArtistCandidatesContext(ctx context.Context, artist string, limit int, genres ...string) ([]Candidate, error) {
+	data, err := c.request(ctx, c.searchUrl(apiUrlArtist, limit, "artist", artist, "genre", genres))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCandidates(data, "artist")
+}
+
+// TrackCandidates gets up to limit tracks matching the query from the Spotify
+// database, with their metadata and artworks, in the order of relevance of
+// the API. DefaultLimit candidates are requested when limit is zero.
+func (c *Client) TrackCandidates(track string, limit int, artists ...string) ([]Candidate, error) {
+	return c.TrackCandidatesContext(context.Background(), track, limit, artists...)
+}
+
+// TrackCandidatesContext is like TrackCandidates, but the request is bound to
+// ctx so it can be cancelled or given a deadline.
+func (c *Client) TrackCandidatesContext(ctx context.Context, track string, limit int, artists ...string) ([]Candidate, error) {
+	data, err := c.request(ctx, c.searchUrl(apiUrlTrack, limit, "track", track, "artist", artists))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCandidates(data, "track")
+}
+
+// AlbumCover gets the album artwork with the default client
+func AlbumCover(album string, artists ...string) (Result, error) {
+	return defaultClient.AlbumCover(album, artists...)
+}
+
+// AlbumCoverContext gets the album artwork with the default client, the request is
+// bound to ctx
+func AlbumCoverContext(ctx context.Context, album string, artists ...string) (Result, error) {
+	return defaultClient.AlbumCoverContext(ctx, album, artists...)
+}
+
+// ArtistCover gets the artist artwork with the default client
+func ArtistCover(artist string, genres ...string) (Result, error) {
+	return defaultClient.ArtistCover(artist, genres...)
+}
+
+// ArtistCoverContext gets the artist artwork with the default client, the request is
+// bound to ctx
+func ArtistCoverContext(ctx context.Context, artist string, genres ...string) (Result, error) {
+	return defaultClient.ArtistCoverContext(ctx, artist, genres...)
+}
+
+// TrackCover gets the track artwork with the default client
+func TrackCover(track string, artists ...string) (Result, error) {
+	return defaultClient.TrackCover(track, artists...)
+}
+
+// TrackCoverContext gets the track artwork with the default client, the request is
+// bound to ctx
+func TrackCoverContext(ctx context.Context, track string, artists ...string) (Result, error) {
+	return defaultClient.TrackCoverContext(ctx, track, artists...)
+}
+
+// AlbumCandidates gets the album candidates with the default client
+func AlbumCandidates(album string, limit int, artists ...string) ([]Candidate, error) {
+	return defaultClient.AlbumCandidates(album, limit, artists...)
+}
+
+// AlbumCandidatesContext gets the album candidates with the default client,
+// the request is bound to ctx
+func AlbumCandidatesContext(ctx context.Context, album string, limit int, artists ...string) ([]Candidate, error) {
+	return defaultClient.AlbumCandidatesContext(ctx, album, limit, artists...)
+}
+
+// ArtistCandidates gets the artist candidates with the default client
+func ArtistCandidates(artist string, limit int, genres ...string) ([]Candidate, error) {
+	return defaultClient.ArtistCandidates(artist, limit, genres...)
+}
+
+// ArtistCandidatesContext gets the artist candidates with the default client,
+// the request is bound to ctx
+func ArtistCandidatesContext(ctx context.Context, artist string, limit int, genres ...string) ([]Candidate, error) {
+	return defaultClient.ArtistCandidatesContext(ctx, artist, limit, genres...)
+}
+
+// TrackCandidates gets the track candidates with the default client
+func TrackCandidates(track string, limit int, artists ...string) ([]Candidate, error) {
+	return defaultClient.TrackCandidates(track, limit, artists...)
+}
+
+// TrackCandidatesContext gets the track candidates with the default client,
+// the request is bound to ctx
+func TrackCandidatesContext(ctx context.Context, track string, limit int, artists ...string) ([]Candidate, error) {
+	return defaultClient.TrackCandidatesContext(ctx, track, limit, artists...)
+}
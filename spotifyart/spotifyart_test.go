@@ -1,9 +1,16 @@
 package spotifyart_test
 
 import (
+	"errors"
 	"fmt"
+	"github.com/piraveen/go-coverart/artwork"
 	"github.com/piraveen/go-coverart/spotifyart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestAlbumCover(t *testing.T) {
@@ -30,6 +37,253 @@ func TestTrackCover(t *testing.T) {
 	}
 }
 
+func TestClientCredentials(t *testing.T) {
+	a := spotifyart.NewClient("id", "secret")
+	b := spotifyart.NewClient("id", "")
+
+	if !a.CheckCredentials() {
+		t.Errorf("expected the credentials of the first client to be set")
+	}
+
+	if b.CheckCredentials() {
+		t.Errorf("expected the credentials of the second client not to be set")
+	}
+
+	if spotifyart.CheckCredentials() {
+		t.Errorf("expected the credentials of the default client not to be set")
+	}
+}
+
+func TestClientBaseURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+
+		fmt.Fprint(w, `{"albums": {"items": [{"name": "Halcyon Days", "images": [
+			{"url": "640.jpg", "width": 640, "height": 640},
+			{"url": "300.jpg", "width": 300, "height": 300},
+			{"url": "64.jpg", "width": 64, "height": 64}
+		]}]}}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := spotifyart.NewClient("id", "secret")
+	client.HTTPClient = ts.Client()
+	client.BaseURL = ts.URL + "/v1"
+	client.TokenURL = ts.URL + "/token"
+
+	if err := client.GetAccessToken(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	results, err := client.AlbumCover("halcyon days", "ellie goulding")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if results.Large != "640.jpg" || results.Small != "64.jpg" || results.Default != "640.jpg" {
+		t.Errorf("unexpected result %+v", results)
+	}
+	a := results.Artwork()
+	if src, ok := a.Largest(); a.Provider != "spotify" || len(a.Images) != 3 || !ok || src.URL != "640.jpg" || src.Height != 640 || a.Images[0].Width != 64 {
+		t.Errorf("unexpected artwork %+v", a)
+	}
+}
+
+func TestClientCandidates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("limit") != "50" || q.Get("type") != "album" || q.Get("q") != "album:halcyon days artist:ellie goulding" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		fmt.Fprint(w, `{"albums": {"items": [
+			{"id": "1", "name": "Halcyon Days", "album_type": "album", "release_date": "2013-08-23", "total_tracks": 26,
+				"artists": [{"name": "Ellie Goulding"}], "images": [{"url": "640.jpg"}, {"url": "300.jpg"}, {"url": "64.jpg"}]},
+			{"id": "2", "name": "Halcyon Days (Live)", "images": []},
+			{"id": "3", "name": "Halcyon", "album_type": "album", "release_date": "2012", "artists": [{"name": "Ellie Goulding"}], "images": [{"url": "3.jpg"}]}
+		]}}`)
+	}))
+	defer ts.Close()
+
+	client := spotifyart.NewClient("", "")
+	client.BaseURL = ts.URL
+
+	candidates, err := client.AlbumCandidates("halcyon days", 100, "ellie goulding")
+	if err != nil || len(candidates) != 2 {
+		t.Fatalf("unexpected candidates %+v %v", candidates, err)
+	}
+
+	first := candidates[0]
+	if first.ID != "1" || first.Album != "Halcyon Days" || first.ReleaseDate != "2013-08-23" || first.TrackCount != 26 || len(first.Artists) != 1 || first.Result.Large != "640.jpg" {
+		t.Errorf("unexpected candidate %+v", first)
+	}
+
+	if candidates[1].ID != "3" || candidates[1].Result.Default != "3.jpg" {
+		t.Errorf("unexpected candidate %+v", candidates[1])
+	}
+
+	if a := first.Artwork(); a.ID != "1" || a.Name != "Halcyon Days" || a.Artist != "Ellie Goulding" || a.Retrieved.IsZero() {
+		t.Errorf("unexpected artwork %+v", a)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error": {"status": 429, "message": "API rate limit exceeded"}}`)
+	}))
+	defer ts.Close()
+
+	client := spotifyart.NewClient("", "")
+	client.BaseURL = ts.URL
+	client.Retry = artwork.NoRetry
+
+	_, err := client.ArtistCover("ellie goulding")
+
+	var rateErr *artwork.RateLimitError
+	if !errors.As(err, &rateErr) || rateErr.RetryAfter.Seconds() != 5 {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+
+	if !errors.Is(err, artwork.ErrRateLimited) || err.Error() != "API rate limit exceeded" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if err := client.GetAccessToken(); !errors.Is(err, artwork.ErrUnauthorized) {
+		t.Errorf("expected an unauthorized error without credentials, got %v", err)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error": {"status": 429, "message": "API rate limit exceeded"}}`)
+			return
+		}
+
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"artists": {"items": [{"name": "Ellie Goulding", "images": [{"url": "640.jpg"}]}]}}`)
+	}))
+	defer ts.Close()
+
+	client := spotifyart.NewClient("id", "secret")
+	client.BaseURL = ts.URL
+	client.TokenURL = ts.URL + "/token"
+	client.Retry = artwork.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+
+	if err := client.GetAccessToken(); err != nil || attempts != 2 {
+		t.Fatalf("expected the token request to be retried once, got %d attempts and %v", attempts, err)
+	}
+
+	attempts = 0
+	results, err := client.ArtistCover("ellie goulding")
+	if err != nil || attempts != 2 {
+		t.Fatalf("expected the request to be retried once, got %d attempts and %v", attempts, err)
+	}
+
+	if results.Default != "640.jpg" {
+		t.Errorf("unexpected result %+v", results)
+	}
+}
+
+func TestClientTokenRefresh(t *testing.T) {
+	var tokens, revoked int32
+	var authorization atomic.Value
+	expiresIn := int32(3600)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			n := atomic.AddInt32(&tokens, 1)
+			fmt.Fprintf(w, `{"access_token": "token%d", "token_type": "Bearer", "expires_in": %d}`, n, atomic.LoadInt32(&expiresIn))
+			return
+		}
+
+		authorization.Store(r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == fmt.Sprintf("Bearer token%d", atomic.LoadInt32(&revoked)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": {"status": 401, "message": "The access token expired"}}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"albums": {"items": [{"name": "Halcyon Days", "images": [{"url": "640.jpg"}]}]}}`)
+	}))
+	defer ts.Close()
+
+	client := spotifyart.NewClient("id", "secret")
+	client.BaseURL = ts.URL
+	client.TokenURL = ts.URL + "/token"
+
+	// The token is requested once by concurrent requests
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.AlbumCover("halcyon days", "ellie goulding")
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&tokens); n != 1 {
+		t.Errorf("expected a single token request, got %d", n)
+	}
+
+	// A request rejected because of its token is retried with a new one
+	atomic.StoreInt32(&revoked, 1)
+	if _, err := client.AlbumCover("halcyon days", "ellie goulding"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if n := atomic.LoadInt32(&tokens); n != 2 {
+		t.Errorf("expected the token to be refreshed once, got %d token requests", n)
+	}
+
+	// A short lived token is used for half of its lifetime, then refreshed
+	// before the request
+	atomic.StoreInt32(&expiresIn, 1)
+	client.GetAccessToken()
+	client.AlbumCover("halcyon days", "ellie goulding")
+
+	if n, auth := atomic.LoadInt32(&tokens), authorization.Load(); n != 3 || auth != "Bearer token3" {
+		t.Errorf("expected the short lived token to be used, got %d token requests and %v", n, auth)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	client.AlbumCover("halcyon days", "ellie goulding")
+
+	if n, auth := atomic.LoadInt32(&tokens), authorization.Load(); n != 4 || auth != "Bearer token4" {
+		t.Errorf("expected the token to be refreshed before expiring, got %d token requests and %v", n, auth)
+	}
+}
+
+func ExampleNewClient() {
+	// Every client owns its credentials and access token
+	client := spotifyart.NewClient("SPOTIFY_CLIENTID", "SPOTIFY_CLIENTSECRET")
+	if err := client.GetAccessToken(); err != nil {
+		// Abort action
+		return
+	}
+
+	results, err := client.AlbumCover("halcyon days", "ellie goulding")
+	if err == nil {
+		fmt.Printf("AlbumCover %v\n", results.Default)
+	}
+}
+
 func ExampleAlbumCover() {
 	// The API Keys can be defined in your code itself, however I recommend
 	// loading them through an environment variable like this: